@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/DuganChandler/goserver/internal/auth"
+	"github.com/DuganChandler/goserver/internal/database"
+)
+
+func (cfg *apiConfig) createBackupHandler(w http.ResponseWriter, req *http.Request) {
+	if !cfg.authorizeAdmin(w, req) {
+		return
+	}
+
+	backup, err := database.CreateBackup(cfg.DB, cfg.BackupDir, cfg.BackupRetention)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responseWithJSON(w, http.StatusCreated, backup)
+}
+
+func (cfg *apiConfig) listBackupsHandler(w http.ResponseWriter, req *http.Request) {
+	if !cfg.authorizeAdmin(w, req) {
+		return
+	}
+
+	backups, err := database.ListBackups(cfg.BackupDir)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responseWithJSON(w, http.StatusOK, backups)
+}
+
+func (cfg *apiConfig) restoreBackupHandler(w http.ResponseWriter, req *http.Request) {
+	if !cfg.authorizeAdmin(w, req) {
+		return
+	}
+
+	err := database.RestoreBackup(cfg.DB, cfg.BackupDir, req.PathValue("backupName"))
+	if errors.Is(err, database.ErrBackupNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authorizeAdmin checks the request carries ADMIN_API_KEY and responds with
+// 401 if it doesn't. An unset key locks the admin API entirely.
+func (cfg *apiConfig) authorizeAdmin(w http.ResponseWriter, req *http.Request) bool {
+	apiKey, err := auth.GetAPIKey(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "no api key present")
+		return false
+	}
+
+	if cfg.AdminAPIKey == "" || apiKey != cfg.AdminAPIKey {
+		respondWithError(w, http.StatusUnauthorized, "incorrect api key")
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DuganChandler/goserver/internal/database"
+)
+
+// parseChirpQuery reads the filters and sort of GET /api/chirps:
+//
+//	author_id       one or more author ids, comma separated or repeated
+//	created_after   RFC 3339 time, exclusive
+//	created_before  RFC 3339 time, exclusive
+//	contains        case insensitive substring of the body
+//	chirpy_red      true to only list chirps by Chirpy Red members
+//	sort_by         id (default) or created_at
+//	sort            asc (default) or desc
+func parseChirpQuery(req *http.Request) (database.ChirpQuery, error) {
+	params := req.URL.Query()
+	query := database.ChirpQuery{}
+
+	for _, val := range params["author_id"] {
+		for _, id := range strings.Split(val, ",") {
+			authorID, err := strconv.Atoi(strings.TrimSpace(id))
+			if err != nil || authorID < 1 {
+				return database.ChirpQuery{}, fmt.Errorf("invalid author_id: %q", id)
+			}
+			query.AuthorIDs = append(query.AuthorIDs, authorID)
+		}
+	}
+
+	var err error
+	query.CreatedAfter, err = parseTimeParam(req, "created_after")
+	if err != nil {
+		return database.ChirpQuery{}, err
+	}
+	query.CreatedBefore, err = parseTimeParam(req, "created_before")
+	if err != nil {
+		return database.ChirpQuery{}, err
+	}
+
+	query.BodyContains = params.Get("contains")
+
+	if val := params.Get("chirpy_red"); val != "" {
+		query.ChirpyRedOnly, err = strconv.ParseBool(val)
+		if err != nil {
+			return database.ChirpQuery{}, fmt.Errorf("chirpy_red must be true or false")
+		}
+	}
+
+	switch params.Get("sort_by") {
+	case "", "id":
+		query.SortBy = database.SortByID
+	case "created_at":
+		query.SortBy = database.SortByCreatedAt
+	default:
+		return database.ChirpQuery{}, fmt.Errorf("sort_by must be id or created_at")
+	}
+
+	switch params.Get("sort") {
+	case "", "asc":
+	case "desc":
+		query.Descending = true
+	default:
+		return database.ChirpQuery{}, fmt.Errorf("sort must be asc or desc")
+	}
+
+	return query, nil
+}
+
+// newestFirstByDefault sorts feeds newest first when the request does not
+// pick an order
+func newestFirstByDefault(req *http.Request, query database.ChirpQuery) database.ChirpQuery {
+	if !req.URL.Query().Has("sort_by") && !req.URL.Query().Has("sort") {
+		query.SortBy = database.SortByCreatedAt
+		query.Descending = true
+	}
+
+	return query
+}
+
+// parseTimeParam reads an optional RFC 3339 time from the query string
+func parseTimeParam(req *http.Request, name string) (time.Time, error) {
+	val := req.URL.Query().Get(name)
+	if val == "" {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, val)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 time", name)
+	}
+
+	return t, nil
+}
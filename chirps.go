@@ -2,18 +2,22 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
-	"sort"
 	"strconv"
-	"strings"
+	"time"
 
 	"github.com/DuganChandler/goserver/internal/auth"
 	"github.com/DuganChandler/goserver/internal/database"
 )
 
+const maxChirpLength = 140
+
 func (cfg *apiConfig) createChirpsHandler(w http.ResponseWriter, req *http.Request) {
 	type parameters struct {
-		Body string `json:"body"`
+		Body      string `json:"body"`
+		InReplyTo int    `json:"in_reply_to"`
+		QuoteOf   int    `json:"quote_of"`
 	}
 
 	token, err := auth.GetBearerToken(req.Header)
@@ -42,72 +46,81 @@ func (cfg *apiConfig) createChirpsHandler(w http.ResponseWriter, req *http.Reque
 		return
 	}
 
-	const maxChirpLength = 140
 	if len(params.Body) > maxChirpLength {
 		respondWithError(w, http.StatusBadRequest, "Chirp is too long")
 		return
 	}
 
-	params.Body = checkBadWords(params.Body)
+	moderated, ok := cfg.moderateChirp(w, params.Body)
+	if !ok {
+		return
+	}
 
-	chirp, err := cfg.DB.CreateChirp(params.Body, userID)
+	chirp, err := cfg.DB.CreateChirp(database.Chirp{
+		Body:      moderated.Body,
+		AuthorID:  userID,
+		InReplyTo: params.InReplyTo,
+		QuoteOf:   params.QuoteOf,
+	})
+	if errors.Is(err, database.ErrParentNotFound) {
+		respondWithError(w, http.StatusBadRequest, database.ErrParentNotFound.Error())
+		return
+	}
+	if errors.Is(err, database.ErrOriginalNotFound) {
+		respondWithError(w, http.StatusBadRequest, database.ErrOriginalNotFound.Error())
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
+	cfg.flagForReview(chirp.Id, moderated)
 
-	responseWithJSON(w, http.StatusCreated, database.Chirp{
-		Id:       chirp.Id,
-		Body:     chirp.Body,
-		AuthorID: userID,
-	})
+	w.Header().Set("ETag", etag(chirp.Version))
+	responseWithJSON(w, http.StatusCreated, chirp)
 }
 
+// getChirpsHandler lists chirps a page at a time, see parseChirpQuery for
+// the filters
 func (cfg *apiConfig) getChirpsHandler(w http.ResponseWriter, req *http.Request) {
-	query := req.URL.Query().Get("author_id")
+	query, err := parseChirpQuery(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
-    var dbChirps []database.Chirp
-    var err error
-	if query != "" {
-		authorID, err := strconv.Atoi(query)
-		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "unable to make author ID query into int")
-            return
-		}
+	cfg.respondWithChirpPage(w, req, query)
+}
 
-        dbChirps, err = cfg.DB.GetChirpsByAuthor(authorID)
-		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, err.Error())
-            return
-		}
-	} else {
-        dbChirps, err = cfg.DB.GetChirps()
+// respondWithChirpPage responds with the page of chirps selected by query
+// and the cursor and limit parameters of req. When there are more, the Link
+// header points at the next page.
+func (cfg *apiConfig) respondWithChirpPage(w http.ResponseWriter, req *http.Request, query database.ChirpQuery) {
+	limit, err := pageLimit(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if val := req.URL.Query().Get("cursor"); val != "" {
+		query.After, err = decodeChirpCursor(val, req)
 		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, err.Error())
-            return
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
 		}
 	}
 
-	chirps := []database.Chirp{}
-	for _, dbChirp := range dbChirps {
-		chirps = append(chirps, database.Chirp{
-			Id:       dbChirp.Id,
-			Body:     dbChirp.Body,
-			AuthorID: dbChirp.AuthorID,
-		})
+	chirps, err := fetchPage(w, req, limit, func(limit int) ([]database.Chirp, error) {
+		query.Limit = limit
+		return cfg.DB.ListChirps(query)
+	}, func(last database.Chirp) string {
+		return newChirpCursor(last, req)
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
 	}
 
-    sortQuery := req.URL.Query().Get("sort")
-    if sortQuery == "asc" || sortQuery == "" {
-        sort.Slice(chirps, func(i, j int) bool {
-            return chirps[i].Id < chirps[j].Id
-        })
-    } else if sortQuery == "desc" {
-        sort.Slice(chirps, func(i, j int) bool {
-            return chirps[i].Id > chirps[j].Id
-        })
-    }
-
 	responseWithJSON(w, http.StatusOK, chirps)
 }
 
@@ -124,9 +137,119 @@ func (cfg *apiConfig) getChirpByIDHandler(w http.ResponseWriter, req *http.Reque
 		return
 	}
 
+	if notModified(w, req, chirp.Version) {
+		return
+	}
+
 	responseWithJSON(w, http.StatusOK, chirp)
 }
 
+func (cfg *apiConfig) editChirpHandler(w http.ResponseWriter, req *http.Request) {
+	type parameters struct {
+		Body string `json:"body"`
+	}
+
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "no token provided")
+		return
+	}
+
+	subject, err := auth.VerifyJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "unable to verify jwt token")
+		return
+	}
+
+	userID, err := strconv.Atoi(subject)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to turn subject to user id")
+		return
+	}
+
+	chirpID, err := strconv.Atoi(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	params := parameters{}
+	err = decoder.Decode(&params)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Could not decode parameters")
+		return
+	}
+
+	if len(params.Body) > maxChirpLength {
+		respondWithError(w, http.StatusBadRequest, "Chirp is too long")
+		return
+	}
+
+	moderated, ok := cfg.moderateChirp(w, params.Body)
+	if !ok {
+		return
+	}
+
+	chirp, err := cfg.DB.GetChirpByID(chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if chirp.AuthorID != userID {
+		respondWithError(w, http.StatusForbidden, "you do not have authorization to edit provided chirp")
+		return
+	}
+
+	version, ok := checkIfMatch(w, req, chirp.Version)
+	if !ok {
+		return
+	}
+
+	chirp, err = cfg.DB.EditChirp(chirpID, version, moderated.Body)
+	if errors.Is(err, database.ErrVersionMismatch) {
+		respondWithError(w, http.StatusPreconditionFailed, err.Error())
+		return
+	}
+	if errors.Is(err, database.ErrRechirpNotEditable) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if errors.Is(err, database.ErrChirpNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	cfg.flagForReview(chirp.Id, moderated)
+
+	w.Header().Set("ETag", etag(chirp.Version))
+	responseWithJSON(w, http.StatusOK, chirp)
+}
+
+func (cfg *apiConfig) getChirpRevisionsHandler(w http.ResponseWriter, req *http.Request) {
+	chirpID, err := strconv.Atoi(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	revisions, err := cfg.DB.GetChirpRevisions(chirpID)
+	if errors.Is(err, database.ErrChirpNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responseWithJSON(w, http.StatusOK, revisions)
+}
+
 func (cfg *apiConfig) deleteChirpByIDHandler(w http.ResponseWriter, req *http.Request) {
 	token, err := auth.GetBearerToken(req.Header)
 	if err != nil {
@@ -163,19 +286,82 @@ func (cfg *apiConfig) deleteChirpByIDHandler(w http.ResponseWriter, req *http.Re
 		return
 	}
 
-	err = cfg.DB.DeleteChirpByID(chirpID)
+	version, ok := checkIfMatch(w, req, chirp.Version)
+	if !ok {
+		return
+	}
+
+	err = cfg.DB.DeleteChirpByID(chirpID, version)
+	if errors.Is(err, database.ErrVersionMismatch) {
+		respondWithError(w, http.StatusPreconditionFailed, err.Error())
+		return
+	}
+	if errors.Is(err, database.ErrChirpNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func checkBadWords(body string) string {
-	var badWords = map[string]bool{"kerfuffle": true, "sharbert": true, "fornax": true}
-	words := strings.Split(body, " ")
-	for i, word := range words {
-		if _, ok := badWords[strings.ToLower(word)]; ok {
-			words[i] = "****"
-		}
+func (cfg *apiConfig) restoreChirpByIDHandler(w http.ResponseWriter, req *http.Request) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "no token provided")
+		return
+	}
+
+	subject, err := auth.VerifyJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "unable to verify jwt token")
+		return
+	}
+
+	userID, err := strconv.Atoi(subject)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to turn subject to user id")
+		return
+	}
+
+	chirpID, err := strconv.Atoi(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	chirp, err := cfg.DB.GetDeletedChirpByID(chirpID)
+	if errors.Is(err, database.ErrChirpNotFound) || errors.Is(err, database.ErrChirpNotDeleted) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	if chirp.AuthorID != userID {
+		respondWithError(w, http.StatusForbidden, "you do not have authorization to restore provided chirp")
+		return
 	}
 
-	return strings.Join(words, " ")
+	chirp, err = cfg.DB.RestoreChirpByID(chirpID, time.Now().Add(-cfg.ChirpRetention))
+	if errors.Is(err, database.ErrRestoreWindowExpired) {
+		respondWithError(w, http.StatusGone, err.Error())
+		return
+	}
+	if errors.Is(err, database.ErrOriginalNotFound) || errors.Is(err, database.ErrAlreadyRechirped) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.Header().Set("ETag", etag(chirp.Version))
+	responseWithJSON(w, http.StatusOK, chirp)
 }
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/DuganChandler/goserver/internal/database"
+)
+
+// runCommand runs a maintenance subcommand instead of the server. They
+// work on the files directly, so stop the server first.
+func runCommand(name string, args []string) error {
+	switch name {
+	case "migrate":
+		return migrateCommand(args)
+	case "backup":
+		return backupCommand(args)
+	case "restore":
+		return restoreCommand(args)
+	case "rotate-key":
+		return rotateKeyCommand(args)
+	default:
+		return fmt.Errorf("unknown command: %s", name)
+	}
+}
+
+func migrateCommand(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "report pending migrations without applying them")
+	flags.Parse(args)
+
+	opts, err := dbOptions()
+	if err != nil {
+		return err
+	}
+
+	driver, path := dbLocation()
+	applied, err := database.Migrate(driver, path, opts, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	if len(applied) == 0 {
+		fmt.Println("db schema is up to date")
+		return nil
+	}
+
+	verb := "applied"
+	if *dryRun {
+		verb = "would apply"
+	}
+	for _, name := range applied {
+		fmt.Printf("%s migration %s\n", verb, name)
+	}
+	return nil
+}
+
+func backupCommand(args []string) error {
+	flags := flag.NewFlagSet("backup", flag.ExitOnError)
+	list := flags.Bool("list", false, "list existing backups instead of taking one")
+	flags.Parse(args)
+
+	dir, retention, err := backupConfig()
+	if err != nil {
+		return err
+	}
+
+	if *list {
+		backups, err := database.ListBackups(dir)
+		if err != nil {
+			return err
+		}
+		for _, backup := range backups {
+			fmt.Printf("%s\t%d bytes\n", backup.Name, backup.Size)
+		}
+		return nil
+	}
+
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	backup, err := database.CreateBackup(db, dir, retention)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("wrote backup %s\n", backup.Name)
+	return nil
+}
+
+func restoreCommand(args []string) error {
+	flags := flag.NewFlagSet("restore", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: restore <backup name>")
+	}
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return fmt.Errorf("restore needs exactly one backup name")
+	}
+
+	dir, _, err := backupConfig()
+	if err != nil {
+		return err
+	}
+
+	db, err := openDB()
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	err = database.RestoreBackup(db, dir, flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("restored backup %s\n", flags.Arg(0))
+	return nil
+}
+
+// rotateKeyCommand re-encrypts the db and its backups from the currently
+// configured key to a new one. Update the key settings before restarting.
+func rotateKeyCommand(args []string) error {
+	flags := flag.NewFlagSet("rotate-key", flag.ExitOnError)
+	newKeyFile := flags.String("new-key-file", "", "file holding the new base64 encoded key")
+	decrypt := flags.Bool("decrypt", false, "remove encryption instead of rotating to a new key")
+	flags.Parse(args)
+
+	driver, path := dbLocation()
+	if driver != "" && driver != "json" {
+		return database.ErrEncryptionNotSupported
+	}
+
+	oldKey, err := encryptionKey()
+	if err != nil {
+		return err
+	}
+
+	var newKey []byte
+	switch {
+	case *decrypt && *newKeyFile != "":
+		return fmt.Errorf("-decrypt and -new-key-file are mutually exclusive")
+	case *newKeyFile != "":
+		newKey, err = readEncryptionKeyFile(*newKeyFile)
+		if err != nil {
+			return err
+		}
+	case !*decrypt:
+		return fmt.Errorf("rotate-key needs -new-key-file or -decrypt")
+	}
+
+	err = database.RotateEncryptionKey(path, oldKey, newKey)
+	if err != nil {
+		return err
+	}
+
+	dir, _, err := backupConfig()
+	if err != nil {
+		return err
+	}
+	n, err := database.ReencryptBackups(dir, oldKey, newKey)
+	if err != nil {
+		return fmt.Errorf("db was rotated but backups were not: %s", err)
+	}
+
+	fmt.Printf("rotated %s and %d backups, update the configured key before restarting\n", path, n)
+	return nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/DuganChandler/goserver/internal/database"
+)
+
+// etag is the strong validator for a resource at version
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// checkIfMatch enforces If-Match against the current version of a resource
+// and returns the version to pass on to the db, so a write that races with
+// another one after the check still fails with database.ErrVersionMismatch.
+// It responds with 412 and returns false when the precondition fails.
+func checkIfMatch(w http.ResponseWriter, req *http.Request, current int) (int, bool) {
+	header := req.Header.Get("If-Match")
+	if header == "" {
+		return database.AnyVersion, true
+	}
+
+	if !matchesETag(header, etag(current), false) {
+		w.Header().Set("ETag", etag(current))
+		respondWithError(w, http.StatusPreconditionFailed, "resource has been modified, fetch it again and retry")
+		return 0, false
+	}
+
+	return current, true
+}
+
+// notModified handles If-None-Match on a GET, it sets the ETag and returns
+// true when a 304 has been written
+func notModified(w http.ResponseWriter, req *http.Request, current int) bool {
+	w.Header().Set("ETag", etag(current))
+
+	header := req.Header.Get("If-None-Match")
+	if header == "" || !matchesETag(header, etag(current), true) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// matchesETag reports whether a comma separated If-Match or If-None-Match
+// list contains tag. If-None-Match uses weak comparison, If-Match strong.
+func matchesETag(header, tag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			candidate = strings.TrimPrefix(candidate, "W/")
+		}
+		if candidate == tag {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DuganChandler/goserver/internal/auth"
+	"github.com/DuganChandler/goserver/internal/database"
+	"github.com/DuganChandler/goserver/internal/moderation"
+)
+
+// testServer serves the chirp and user routes of a fresh apiConfig, with one user
+// whose bearer token is returned
+func testServer(t *testing.T) (*apiConfig, *http.ServeMux, string) {
+	t.Helper()
+
+	db, err := database.NewDB(filepath.Join(t.TempDir(), "database.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	cfg := &apiConfig{DB: db, JWTSecret: "secret", Moderator: moderation.Default()}
+	user, err := db.CreateUsers(database.User{Email: "user@example.com", Handle: "user", Password: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := auth.MakeJWT(user.Id, cfg.JWTSecret, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/chirps", cfg.getChirpsHandler)
+	mux.HandleFunc("GET /api/chirps/search", cfg.searchChirpsHandler)
+	mux.HandleFunc("GET /api/chirps/{chirpID}", cfg.getChirpByIDHandler)
+	mux.HandleFunc("POST /api/chirps", cfg.createChirpsHandler)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", cfg.editChirpHandler)
+	mux.HandleFunc("POST /api/users", cfg.createUsersHandler)
+	mux.HandleFunc("GET /api/users/{handle}", cfg.getUserProfileHandler)
+	mux.HandleFunc("PUT /api/users", cfg.updateUsersLoginHandler)
+	return cfg, mux, token
+}
+
+func serve(mux *http.ServeMux, method, path, body string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestEditChirpWithStaleIfMatch(t *testing.T) {
+	_, mux, token := testServer(t)
+	bearer := http.Header{"Authorization": {"Bearer " + token}}
+
+	rec := serve(mux, "POST", "/api/chirps", `{"body": "first"}`, bearer)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("got %d creating a chirp: %s", rec.Code, rec.Body)
+	}
+	created := rec.Header().Get("ETag")
+
+	rec = serve(mux, "PUT", "/api/chirps/1", `{"body": "second"}`, http.Header{
+		"Authorization": bearer["Authorization"],
+		"If-Match":      {created},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d editing with a fresh If-Match: %s", rec.Code, rec.Body)
+	}
+	edited := rec.Header().Get("ETag")
+	if edited == created {
+		t.Errorf("edit kept ETag %s", edited)
+	}
+
+	rec = serve(mux, "PUT", "/api/chirps/1", `{"body": "third"}`, http.Header{
+		"Authorization": bearer["Authorization"],
+		"If-Match":      {created},
+	})
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("got %d editing with a stale If-Match, want 412", rec.Code)
+	}
+	if got := rec.Header().Get("ETag"); got != edited {
+		t.Errorf("412 carried ETag %s, want the current %s", got, edited)
+	}
+
+	rec = serve(mux, "GET", "/api/chirps/1", "", nil)
+	if !strings.Contains(rec.Body.String(), `"second"`) {
+		t.Errorf("stale edit was applied: %s", rec.Body)
+	}
+}
+
+func TestGetChirpNotModified(t *testing.T) {
+	_, mux, token := testServer(t)
+	bearer := http.Header{"Authorization": {"Bearer " + token}}
+
+	rec := serve(mux, "POST", "/api/chirps", `{"body": "first"}`, bearer)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("got %d creating a chirp: %s", rec.Code, rec.Body)
+	}
+	tag := rec.Header().Get("ETag")
+
+	rec = serve(mux, "GET", "/api/chirps/1", "", http.Header{"If-None-Match": {`"99", W/` + tag}})
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("got %d for a matching If-None-Match, want 304", rec.Code)
+	}
+	if rec.Body.Len() != 0 {
+		t.Errorf("304 had a body: %s", rec.Body)
+	}
+
+	rec = serve(mux, "GET", "/api/chirps/1", "", http.Header{"If-None-Match": {`"99"`}})
+	if rec.Code != http.StatusOK {
+		t.Errorf("got %d for a stale If-None-Match, want 200", rec.Code)
+	}
+	if got := rec.Header().Get("ETag"); got != tag {
+		t.Errorf("got ETag %s, want %s", got, tag)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/DuganChandler/goserver/internal/database"
+)
+
+func (cfg *apiConfig) getEventsHandler(w http.ResponseWriter, req *http.Request) {
+	type response struct {
+		Events []database.Event `json:"events"`
+		// Cursor is the value to pass as after to continue from here
+		Cursor int `json:"cursor"`
+	}
+
+	if !cfg.authorizeAdmin(w, req) {
+		return
+	}
+
+	after := 0
+	if val := req.URL.Query().Get("after"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 0 {
+			respondWithError(w, http.StatusBadRequest, "after must be a non-negative event sequence number")
+			return
+		}
+		after = n
+	}
+
+	const defaultLimit, maxLimit = 100, 1000
+	limit := defaultLimit
+	if val := req.URL.Query().Get("limit"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 1 || n > maxLimit {
+			respondWithError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		limit = n
+	}
+
+	events, err := cfg.DB.GetEvents(after, limit)
+	if errors.Is(err, database.ErrEventsPurged) {
+		respondWithError(w, http.StatusGone, "events after this cursor have been purged, start again from after=0")
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	cursor := after
+	if len(events) > 0 {
+		cursor = events[len(events)-1].Seq
+	}
+
+	responseWithJSON(w, http.StatusOK, response{
+		Events: events,
+		Cursor: cursor,
+	})
+}
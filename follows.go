@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/DuganChandler/goserver/internal/auth"
+	"github.com/DuganChandler/goserver/internal/database"
+)
+
+// followHandler makes the current user follow another, following them
+// again is a no-op
+func (cfg *apiConfig) followHandler(w http.ResponseWriter, req *http.Request) {
+	cfg.setFollow(w, req, cfg.DB.Follow)
+}
+
+// unfollowHandler stops the current user following another, if they did
+func (cfg *apiConfig) unfollowHandler(w http.ResponseWriter, req *http.Request) {
+	cfg.setFollow(w, req, cfg.DB.Unfollow)
+}
+
+func (cfg *apiConfig) setFollow(w http.ResponseWriter, req *http.Request, set func(followerID, followeeID int) (bool, error)) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "no token provided")
+		return
+	}
+
+	subject, err := auth.VerifyJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "unable to verify jwt token")
+		return
+	}
+
+	userID, err := strconv.Atoi(subject)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to turn subject to user id")
+		return
+	}
+
+	followeeID, err := strconv.Atoi(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	_, err = set(userID, followeeID)
+	if errors.Is(err, database.ErrUserNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if errors.Is(err, database.ErrFollowSelf) {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getFollowersHandler lists who follows a user, most recent first
+func (cfg *apiConfig) getFollowersHandler(w http.ResponseWriter, req *http.Request) {
+	cfg.respondWithFollows(w, req, cfg.DB.GetFollowers)
+}
+
+// getFollowingHandler lists who a user follows, most recent first
+func (cfg *apiConfig) getFollowingHandler(w http.ResponseWriter, req *http.Request) {
+	cfg.respondWithFollows(w, req, cfg.DB.GetFollowing)
+}
+
+func (cfg *apiConfig) respondWithFollows(w http.ResponseWriter, req *http.Request, list func(userID, offset, limit int) ([]database.Follow, error)) {
+	userID, err := strconv.Atoi(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	limit, offset, ok := offsetPage(w, req)
+	if !ok {
+		return
+	}
+
+	follows, err := fetchOffsetPage(w, req, offset, limit, func(offset, limit int) ([]database.Follow, error) {
+		return list(userID, offset, limit)
+	})
+	if errors.Is(err, database.ErrUserNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responseWithJSON(w, http.StatusOK, follows)
+}
+
+// getTimelineHandler lists the chirps of the users the current user
+// follows, newest first unless sort_by or sort say otherwise, taking the
+// same parameters as GET /api/chirps
+func (cfg *apiConfig) getTimelineHandler(w http.ResponseWriter, req *http.Request) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "no token provided")
+		return
+	}
+
+	subject, err := auth.VerifyJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "unable to verify jwt token")
+		return
+	}
+
+	userID, err := strconv.Atoi(subject)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to turn subject to user id")
+		return
+	}
+
+	query, err := parseChirpQuery(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query = newestFirstByDefault(req, query)
+	query.FollowedBy = userID
+
+	cfg.respondWithChirpPage(w, req, query)
+}
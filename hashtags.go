@@ -0,0 +1,97 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/DuganChandler/goserver/internal/database"
+)
+
+const (
+	defaultTrendingWindow = 24 * time.Hour
+	maxTrendingWindow     = 30 * 24 * time.Hour
+	defaultTrendingTags   = 10
+	maxTrendingTags       = 100
+)
+
+// getHashtagChirpsHandler lists the chirps tagged with a hashtag a page at
+// a time, taking the same parameters as GET /api/chirps
+func (cfg *apiConfig) getHashtagChirpsHandler(w http.ResponseWriter, req *http.Request) {
+	tag, ok := database.NormalizeHashtag(req.PathValue("tag"))
+	if !ok {
+		respondWithError(w, http.StatusBadRequest, "invalid hashtag")
+		return
+	}
+
+	query, err := parseChirpQuery(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query.Hashtag = tag
+
+	cfg.respondWithChirpPage(w, req, query)
+}
+
+// getTrendingHashtagsHandler ranks the hashtags used in the last window,
+// 24h by default. Every half_life, a quarter of the window by default, a
+// use counts half as much, so tags picking up now outrank ones that peaked
+// earlier in the window.
+func (cfg *apiConfig) getTrendingHashtagsHandler(w http.ResponseWriter, req *http.Request) {
+	window, err := parseDurationParam(req, "window", defaultTrendingWindow)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if window > maxTrendingWindow {
+		respondWithError(w, http.StatusBadRequest, fmt.Sprintf("window must be at most %s", maxTrendingWindow))
+		return
+	}
+
+	halfLife, err := parseDurationParam(req, "half_life", window/4)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	limit := defaultTrendingTags
+	if val := req.URL.Query().Get("limit"); val != "" {
+		limit, err = strconv.Atoi(val)
+		if err != nil || limit < 1 || limit > maxTrendingTags {
+			respondWithError(w, http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxTrendingTags))
+			return
+		}
+	}
+
+	now := time.Now()
+	trending, err := cfg.DB.TrendingHashtags(database.TrendingQuery{
+		Since:    now.Add(-window),
+		Now:      now,
+		HalfLife: halfLife,
+		Limit:    limit,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responseWithJSON(w, http.StatusOK, trending)
+}
+
+// parseDurationParam reads an optional positive duration like 90m from the
+// query string
+func parseDurationParam(req *http.Request, name string, fallback time.Duration) (time.Duration, error) {
+	val := req.URL.Query().Get(name)
+	if val == "" {
+		return fallback, nil
+	}
+
+	d, err := time.ParseDuration(val)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration like 90m or 24h", name)
+	}
+
+	return d, nil
+}
@@ -0,0 +1,177 @@
+package database
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	backupPrefix     = "chirpy-"
+	backupSuffix     = ".bak.gz"
+	backupTimeFormat = "20060102T150405.000000000Z"
+)
+
+var ErrBackupNotFound = errors.New("backup not found")
+
+type BackupInfo struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreateBackup writes a gzipped snapshot of store into dir, then deletes
+// all but the newest keep backups. keep <= 0 keeps everything.
+func CreateBackup(store Store, dir string, keep int) (BackupInfo, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return BackupInfo{}, fmt.Errorf("unable to create backup dir: %s", err)
+	}
+
+	createdAt := time.Now().UTC()
+	name := backupPrefix + createdAt.Format(backupTimeFormat) + backupSuffix
+
+	tmp, err := os.CreateTemp(dir, name+".tmp-*")
+	if err != nil {
+		return BackupInfo{}, err
+	}
+	defer os.Remove(tmp.Name())
+
+	gz := gzip.NewWriter(tmp)
+	err = store.Backup(gz)
+	if err == nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return BackupInfo{}, fmt.Errorf("unable to write backup: %s", err)
+	}
+
+	err = os.Rename(tmp.Name(), filepath.Join(dir, name))
+	if err != nil {
+		return BackupInfo{}, err
+	}
+
+	info, err := os.Stat(filepath.Join(dir, name))
+	if err != nil {
+		return BackupInfo{}, err
+	}
+
+	err = pruneBackups(dir, keep)
+	if err != nil {
+		return BackupInfo{}, err
+	}
+
+	return BackupInfo{
+		Name:      name,
+		Size:      info.Size(),
+		CreatedAt: createdAt,
+	}, nil
+}
+
+// ListBackups returns the backups in dir, newest first
+func ListBackups(dir string) ([]BackupInfo, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return []BackupInfo{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []BackupInfo{}
+	for _, entry := range entries {
+		createdAt, ok := parseBackupName(entry.Name())
+		if !ok || !entry.Type().IsRegular() {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, BackupInfo{
+			Name:      entry.Name(),
+			Size:      info.Size(),
+			CreatedAt: createdAt,
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+
+	return backups, nil
+}
+
+// RestoreBackup replaces the contents of store with the backup called name
+// in dir. The store validates the backup before touching live data.
+func RestoreBackup(store Store, dir, name string) error {
+	_, ok := parseBackupName(name)
+	if !ok {
+		return ErrBackupNotFound
+	}
+
+	f, err := os.Open(filepath.Join(dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrBackupNotFound
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return fmt.Errorf("invalid backup: %s", err)
+	}
+	defer gz.Close()
+
+	return store.Restore(gz)
+}
+
+func pruneBackups(dir string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return err
+	}
+
+	for i := keep; i < len(backups); i++ {
+		err = os.Remove(filepath.Join(dir, backups[i].Name))
+		if err != nil {
+			return fmt.Errorf("unable to remove old backup: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// parseBackupName returns the creation time encoded in a backup file name,
+// anything else in the dir, including path tricks, is not a backup
+func parseBackupName(name string) (time.Time, bool) {
+	if filepath.Base(name) != name || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+		return time.Time{}, false
+	}
+
+	stamp := strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupSuffix)
+	createdAt, err := time.Parse(backupTimeFormat, stamp)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return createdAt, true
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+type ChirpSort string
+
+const (
+	SortByID        ChirpSort = "id"
+	SortByCreatedAt ChirpSort = "created_at"
+)
+
+// ChirpQuery selects a page of chirps. Deleted chirps are never included,
+// ties in the sort order are broken by id.
+type ChirpQuery struct {
+	// AuthorIDs limits the results to these authors, empty matches everyone
+	AuthorIDs []int
+	// CreatedAfter and CreatedBefore are exclusive bounds on CreatedAt,
+	// the zero time leaves that side open
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	// BodyContains matches chirps containing it, ignoring case
+	BodyContains string
+	// ChirpyRedOnly matches chirps whose author is a Chirpy Red member
+	ChirpyRedOnly bool
+	// InReplyTo limits the results to the replies to one chirp
+	InReplyTo int
+	// QuoteOf limits the results to the quotes of one chirp
+	QuoteOf int
+	// Hashtag limits the results to chirps tagged with it, in the form
+	// NormalizeHashtag returns
+	Hashtag string
+	// Mentioning limits the results to chirps mentioning one user
+	Mentioning int
+	// FollowedBy limits the results to chirps by the users one user
+	// follows
+	FollowedBy int
+
+	SortBy     ChirpSort
+	Descending bool
+	// After continues a listing from this chirp, exclusive, in the
+	// direction of the sort. The zero value starts from the beginning.
+	After ChirpPosition
+	// Limit caps the number of chirps returned, 0 means no cap
+	Limit int
+}
+
+// ChirpPosition is where a chirp falls in a listing, keep the one of the
+// last chirp of a page to continue from it
+type ChirpPosition struct {
+	ID        int
+	CreatedAt time.Time
+}
+
+func PositionOf(chirp Chirp) ChirpPosition {
+	return ChirpPosition{
+		ID:        chirp.Id,
+		CreatedAt: chirp.CreatedAt,
+	}
+}
+
+// less orders a before b in q's sort, ignoring the direction
+func (q ChirpQuery) less(a, b ChirpPosition) bool {
+	if q.SortBy == SortByCreatedAt && !a.CreatedAt.Equal(b.CreatedAt) {
+		return a.CreatedAt.Before(b.CreatedAt)
+	}
+	return a.ID < b.ID
+}
+
+// follows reports whether chirp comes after q.After in q's order
+func (q ChirpQuery) follows(chirp Chirp) bool {
+	if q.After.ID == 0 {
+		return true
+	}
+	if q.Descending {
+		return q.less(PositionOf(chirp), q.After)
+	}
+	return q.less(q.After, PositionOf(chirp))
+}
+
+// matches applies every filter of q but the author ones to chirp
+func (q ChirpQuery) matches(chirp Chirp) bool {
+	if chirp.DeletedAt != nil || !q.follows(chirp) {
+		return false
+	}
+	if q.InReplyTo != 0 && chirp.InReplyTo != q.InReplyTo {
+		return false
+	}
+	if q.QuoteOf != 0 && chirp.QuoteOf != q.QuoteOf {
+		return false
+	}
+	if q.Hashtag != "" && !slices.Contains(chirp.Hashtags, q.Hashtag) {
+		return false
+	}
+	if q.Mentioning != 0 && !slices.Contains(mentionedUsers(chirp.Mentions), q.Mentioning) {
+		return false
+	}
+	if !q.CreatedAfter.IsZero() && !chirp.CreatedAt.After(q.CreatedAfter) {
+		return false
+	}
+	if !q.CreatedBefore.IsZero() && !chirp.CreatedAt.Before(q.CreatedBefore) {
+		return false
+	}
+	if q.BodyContains != "" && !strings.Contains(strings.ToLower(chirp.Body), strings.ToLower(q.BodyContains)) {
+		return false
+	}
+
+	return true
+}
+
+// sortChirps puts chirps in q's order
+func (q ChirpQuery) sortChirps(chirps []Chirp) {
+	slices.SortFunc(chirps, func(a, b Chirp) int {
+		x, y := PositionOf(a), PositionOf(b)
+		if q.Descending {
+			x, y = y, x
+		}
+		switch {
+		case q.less(x, y):
+			return -1
+		case q.less(y, x):
+			return 1
+		default:
+			return 0
+		}
+	})
+}
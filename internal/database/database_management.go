@@ -4,71 +4,296 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"os"
+	"reflect"
 	"sync"
+	"time"
 )
 
 // create new db
 func NewDB(path string) (*DB, error) {
+	return NewDBWithOptions(path, Options{})
+}
+
+// NewDBWithOptions loads the database at path into memory, creating it if
+// needed, and starts background persistence according to opts.
+func NewDBWithOptions(path string, opts Options) (*DB, error) {
+	if opts.PersistInterval <= 0 {
+		opts.PersistInterval = defaultPersistInterval
+	}
+
+	key, err := newCipherKey(opts.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
 	db := &DB{
 		path: path,
 		mux:  &sync.RWMutex{},
+		opts: opts,
+		key:  key,
+		stop: make(chan struct{}),
+		done: make(chan struct{}),
+	}
+	err = db.ensureDB()
+	if err != nil {
+		return db, err
+	}
+	db.idx = newIndexes(db.data)
+
+	if opts.Persist == PersistBatched {
+		go db.persistLoop()
+	} else {
+		close(db.done)
 	}
-	err := db.ensureDB()
 
-	return db, err
+	return db, nil
 }
 
 // ensure db exists
 func (db *DB) ensureDB() error {
-	_, err := os.ReadFile(db.path)
+	raw, err := os.ReadFile(db.path)
 	if errors.Is(err, os.ErrNotExist) {
-		db.createDB()
+		return db.createDB()
+	}
+	if err != nil {
+		return err
+	}
+	encrypting := db.key != nil && !isSealed(raw)
+
+	dbStructure, replayed, err := db.readDB()
+	if err != nil {
+		return err
+	}
+	db.data = dbStructure
+
+	// a torn line left by a crash must go before anything is appended
+	// after it, so any journal at all is folded into a fresh snapshot
+	journal, err := os.Stat(db.journalPath())
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unable to read journal: %s", err)
+	}
+	journaled := err == nil && journal.Size() > 0
+
+	migrated, err := db.migrate()
+	if err != nil {
+		return err
+	}
+
+	if replayed > 0 {
+		log.Printf("Recovered %d journaled writes into %s", replayed, db.path)
+	}
+	if encrypting {
+		log.Printf("Encrypting %s at rest", db.path)
+	}
+	if journaled || migrated || encrypting {
+		return db.checkpoint()
 	}
 
-	return err
+	return nil
+}
+
+// Close stops background persistence and writes any pending changes to
+// the snapshot
+func (db *DB) Close() error {
+	db.closeOnce.Do(func() {
+		close(db.stop)
+	})
+	<-db.done
+
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	if !db.dirty {
+		return nil
+	}
+	return db.checkpoint()
 }
 
 func (db *DB) createDB() error {
-	dbStructure := DBStructure{
+	db.data = DBStructure{
+		SchemaVersion: latestSchemaVersion(),
 		Chirps:        map[int]Chirp{},
 		Users:         map[int]User{},
 		RefreshTokens: map[string]RefreshToken{},
+		Sequences:     map[string]int{},
+		Events:        map[int]Event{},
+		Revisions:     map[int][]ChirpRevision{},
+		Likes:         map[string]Like{},
+		Follows:       map[string]Follow{},
 	}
-	return db.writeDB(dbStructure)
+	return db.checkpoint()
+}
+
+// View runs fn against a consistent read-only view of the database. fn
+// must not modify dbStructure, use Update for that.
+func (db *DB) View(fn func(*DBStructure) error) error {
+	db.mux.RLock()
+	defer db.mux.RUnlock()
+
+	return fn(&db.data)
 }
 
-func (db *DB) loadDB() (DBStructure, error) {
+// Update runs fn as a single read-modify-write transaction. The write lock
+// is held for the whole call, so no other transaction can interleave, and
+// nothing is written if fn returns an error. Tables must be written through
+// the put and delete helpers in transaction.go, and values already in them
+// replaced rather than mutated in place. db.idx may be read inside fn but
+// reflects the data as it was before fn ran.
+//
+// The change is durable once it is in the journal; when the snapshot
+// itself is rewritten depends on the persistence policy.
+func (db *DB) Update(fn func(*DBStructure) error) error {
 	db.mux.Lock()
 	defer db.mux.Unlock()
 
+	tx := newTxLog()
+	db.data.tx = tx
+	defer func() {
+		db.data.tx = nil
+	}()
+
+	err := fn(&db.data)
+	if err != nil {
+		tx.rollback()
+		return err
+	}
+
+	entries, err := tx.entries()
+	if err != nil {
+		tx.rollback()
+		return fmt.Errorf("unable to encode journal entries: %s", err)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	err = db.appendJournal(entries)
+	if err != nil {
+		tx.rollback()
+		return fmt.Errorf("unable to write to journal: %s", err)
+	}
+
+	// the change is committed once it is journaled, a failed checkpoint
+	// leaves db dirty to be retried by the next write or Close
+	db.idx.apply(tx.changes)
+	db.dirty = true
+	if db.opts.Persist == PersistSync {
+		err = db.checkpoint()
+		if err != nil {
+			log.Printf("unable to persist db, the change is in the journal: %s", err)
+		}
+	}
+
+	return nil
+}
+
+// persistLoop checkpoints pending changes every PersistInterval until the
+// db is closed
+func (db *DB) persistLoop() {
+	defer close(db.done)
+
+	ticker := time.NewTicker(db.opts.PersistInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-db.stop:
+			return
+		case <-ticker.C:
+			db.mux.Lock()
+			if db.dirty {
+				err := db.checkpoint()
+				if err != nil {
+					log.Printf("unable to persist db: %s", err)
+				}
+			}
+			db.mux.Unlock()
+		}
+	}
+}
+
+// readDB returns the snapshot on disk with any journaled writes applied on
+// top, along with the number of journal records replayed
+func (db *DB) readDB() (DBStructure, int, error) {
 	dbStructure := DBStructure{}
 	data, err := os.ReadFile(db.path)
-	if errors.Is(err, os.ErrNotExist) {
-		return dbStructure, err
+	if err != nil {
+		return dbStructure, 0, err
+	}
+
+	data, err = db.key.decode(data)
+	if err != nil {
+		return dbStructure, 0, fmt.Errorf("unable to decrypt db: %w", err)
 	}
 
 	err = json.Unmarshal(data, &dbStructure)
 	if err != nil {
-		return dbStructure, fmt.Errorf("unable to unmarshal json while loading db: %s", err)
+		return dbStructure, 0, fmt.Errorf("unable to unmarshal json while loading db: %s", err)
 	}
 
-	return dbStructure, nil
-}
+	records, err := db.readJournal()
+	if err != nil {
+		return dbStructure, 0, err
+	}
+	for _, entries := range records {
+		for _, entry := range entries {
+			err = applyJournalEntry(&dbStructure, entry)
+			if err != nil {
+				return dbStructure, 0, fmt.Errorf("unable to replay journal: %s", err)
+			}
+		}
+	}
 
-func (db *DB) writeDB(dbStructure DBStructure) error {
-	db.mux.Lock()
-	defer db.mux.Unlock()
+	return dbStructure, len(records), nil
+}
 
-	data, err := json.Marshal(dbStructure)
+// checkpoint atomically replaces the snapshot with the in-memory state and
+// clears the journal it now contains, callers must hold the lock
+func (db *DB) checkpoint() error {
+	data, err := json.Marshal(db.data)
 	if err != nil {
 		return fmt.Errorf("unable to marshal json while writing to db: %s", err)
 	}
 
-	err = os.WriteFile(db.path, data, 0600)
+	data, err = db.key.encode(data)
+	if err != nil {
+		return fmt.Errorf("unable to encrypt db: %s", err)
+	}
+
+	err = writeFileAtomic(db.path, data, 0600)
 	if err != nil {
 		return fmt.Errorf("unable to write to file: %s", err)
 	}
 
+	err = os.Truncate(db.journalPath(), 0)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unable to truncate journal: %s", err)
+	}
+
+	db.dirty = false
 	return nil
 }
+
+// clone copies every map in dbStructure so the copy can be changed without
+// touching the original
+func (dbStructure DBStructure) clone() DBStructure {
+	cloned := dbStructure
+	val := reflect.ValueOf(&cloned).Elem()
+	for i := 0; i < val.NumField(); i++ {
+		field := val.Field(i)
+		if field.Kind() != reflect.Map || field.IsNil() {
+			continue
+		}
+
+		copied := reflect.MakeMapWithSize(field.Type(), field.Len())
+		iter := field.MapRange()
+		for iter.Next() {
+			copied.SetMapIndex(iter.Key(), iter.Value())
+		}
+		field.Set(copied)
+	}
+
+	return cloned
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdateRollsBackFailedTransaction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database.json")
+	db := openJSON(t, path)
+	defer db.Close()
+
+	user, err := db.CreateUsers(User{Email: "kept@example.com", Handle: "kept", Password: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failed := errors.New("failed")
+	err = db.Update(func(dbStructure *DBStructure) error {
+		changed := dbStructure.Users[user.Id]
+		changed.Email = "changed@example.com"
+		dbStructure.putUser(changed)
+		_, err := dbStructure.insertChirp(Chirp{Body: "never written", AuthorID: user.Id})
+		if err != nil {
+			return err
+		}
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("got %v, want the error of the transaction", err)
+	}
+
+	got, err := db.GetUserByEmail("kept@example.com")
+	if err != nil || got.Email != "kept@example.com" {
+		t.Errorf("user was not rolled back: %v", err)
+	}
+	_, err = db.GetUserByEmail("changed@example.com")
+	if !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("index still has the rolled back email: %v", err)
+	}
+	_, err = db.GetChirpByID(1)
+	if err == nil {
+		t.Error("chirp of the failed transaction was kept")
+	}
+
+	// the id handed out by the failed transaction is not burned either
+	chirp, err := db.CreateChirp(Chirp{Body: "written", AuthorID: user.Id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chirp.Id != 1 {
+		t.Errorf("got chirp id %d, want 1", chirp.Id)
+	}
+
+	reopened := openJSON(t, path)
+	defer reopened.Close()
+	_, err = reopened.GetUserByEmail("kept@example.com")
+	if err != nil {
+		t.Errorf("rolled back write reached the journal: %v", err)
+	}
+}
+
+func TestUpdateCommitsWhenCheckpointFails(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database.json")
+	db, err := NewDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	snapshot, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a directory in the way of the snapshot fails every checkpoint
+	err = os.Remove(path)
+	if err == nil {
+		err = os.MkdirAll(filepath.Join(path, "in-the-way"), 0700)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	user, err := db.CreateUsers(User{Email: "kept@example.com", Password: "hash"})
+	if err != nil {
+		t.Fatalf("journaled write reported as failed: %s", err)
+	}
+	if _, err := db.GetUserByID(user.Id); err != nil {
+		t.Errorf("committed user is not visible: %s", err)
+	}
+
+	err = os.RemoveAll(path)
+	if err == nil {
+		err = os.WriteFile(path, snapshot, 0600)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	reopened := openJSON(t, path)
+	defer reopened.Close()
+	if _, err := reopened.GetUserByEmail("kept@example.com"); err != nil {
+		t.Errorf("committed user was not replayed from the journal: %s", err)
+	}
+}
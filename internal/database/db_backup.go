@@ -0,0 +1,181 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// gzipMagic starts every gzip stream
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// Backup writes a consistent copy of the whole database to w
+func (db *DB) Backup(w io.Writer) error {
+	db.mux.RLock()
+	data, err := json.Marshal(db.data)
+	db.mux.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	// ciphertext does not compress, so it is compressed before it is sealed
+	if db.key != nil {
+		data, err = compress(data)
+		if err != nil {
+			return err
+		}
+		data, err = db.key.seal(data)
+		if err != nil {
+			return fmt.Errorf("unable to encrypt backup: %s", err)
+		}
+	}
+
+	_, err = w.Write(data)
+	return err
+}
+
+// Restore replaces the whole database with a copy written by Backup. The
+// copy is migrated and validated first, the live data is left alone if
+// anything is wrong with it.
+func (db *DB) Restore(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("unable to read backup: %s", err)
+	}
+
+	data, err = db.key.decode(data)
+	if err != nil {
+		return fmt.Errorf("unable to decrypt backup: %w", err)
+	}
+	// backups encrypted before they were compressed are plain json inside
+	if bytes.HasPrefix(data, gzipMagic) {
+		data, err = decompress(data)
+		if err != nil {
+			return fmt.Errorf("unable to read backup: %s", err)
+		}
+	}
+
+	restored := DBStructure{}
+	err = json.Unmarshal(data, &restored)
+	if err != nil {
+		return fmt.Errorf("unable to decode backup: %s", err)
+	}
+
+	restored, _, err = runMigrations(restored)
+	if err != nil {
+		return err
+	}
+
+	err = restored.validate()
+	if err != nil {
+		return fmt.Errorf("invalid backup: %s", err)
+	}
+
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	previous := db.data
+	db.data = restored
+	err = db.checkpoint()
+	if err != nil {
+		db.data = previous
+		return err
+	}
+	db.idx = newIndexes(db.data)
+
+	return nil
+}
+
+func compress(data []byte) ([]byte, error) {
+	out := bytes.Buffer{}
+	gz := gzip.NewWriter(&out)
+	_, err := gz.Write(data)
+	if err == nil {
+		err = gz.Close()
+	}
+	return out.Bytes(), err
+}
+
+func decompress(data []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(gz)
+}
+
+// validate checks that dbStructure is internally consistent
+func (dbStructure *DBStructure) validate() error {
+	if dbStructure.Chirps == nil || dbStructure.Users == nil || dbStructure.RefreshTokens == nil || dbStructure.Sequences == nil || dbStructure.Events == nil || dbStructure.Revisions == nil || dbStructure.Likes == nil || dbStructure.Follows == nil {
+		return fmt.Errorf("missing tables")
+	}
+
+	emails := map[string]bool{}
+	for id, user := range dbStructure.Users {
+		if user.Id != id {
+			return fmt.Errorf("user %d stored under id %d", user.Id, id)
+		}
+		if emails[user.Email] {
+			return fmt.Errorf("duplicate email %s", user.Email)
+		}
+		emails[user.Email] = true
+		if id > dbStructure.Sequences[sequenceUsers] {
+			return fmt.Errorf("user %d is past the users sequence", id)
+		}
+	}
+
+	for id, chirp := range dbStructure.Chirps {
+		if chirp.Id != id {
+			return fmt.Errorf("chirp %d stored under id %d", chirp.Id, id)
+		}
+		if id > dbStructure.Sequences[sequenceChirps] {
+			return fmt.Errorf("chirp %d is past the chirps sequence", id)
+		}
+	}
+
+	for id := range dbStructure.Revisions {
+		if _, ok := dbStructure.Chirps[id]; !ok {
+			return fmt.Errorf("revisions of missing chirp %d", id)
+		}
+	}
+
+	for key, like := range dbStructure.Likes {
+		if key != likeKey(like.ChirpID, like.UserID) {
+			return fmt.Errorf("like stored under the wrong key %s", key)
+		}
+		if _, ok := dbStructure.Chirps[like.ChirpID]; !ok {
+			return fmt.Errorf("like of missing chirp %d", like.ChirpID)
+		}
+	}
+
+	for key, follow := range dbStructure.Follows {
+		if key != followKey(follow.FollowerID, follow.FolloweeID) {
+			return fmt.Errorf("follow stored under the wrong key %s", key)
+		}
+		if _, ok := dbStructure.Users[follow.FollowerID]; !ok {
+			return fmt.Errorf("follow by missing user %d", follow.FollowerID)
+		}
+		if _, ok := dbStructure.Users[follow.FolloweeID]; !ok {
+			return fmt.Errorf("follow of missing user %d", follow.FolloweeID)
+		}
+	}
+
+	for seq, event := range dbStructure.Events {
+		if event.Seq != seq {
+			return fmt.Errorf("event %d stored under seq %d", event.Seq, seq)
+		}
+		if seq > dbStructure.Sequences[sequenceEvents] {
+			return fmt.Errorf("event %d is past the events sequence", seq)
+		}
+	}
+
+	for token, refreshToken := range dbStructure.RefreshTokens {
+		if refreshToken.Token != token {
+			return fmt.Errorf("refresh token stored under the wrong key")
+		}
+	}
+
+	return nil
+}
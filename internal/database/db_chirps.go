@@ -2,105 +2,356 @@ package database
 
 import (
 	"fmt"
+	"slices"
+	"time"
 )
 
-// CreateChirp creates a new chirp and saves it to disk
-func (db *DB) CreateChirp(body string, userID int) (Chirp, error) {
-	dbStructure, err := db.loadDB()
+// CreateChirp stores a new chirp from the Body, AuthorID, InReplyTo and
+// QuoteOf of chirp and saves it to disk. Quoting a rechirp quotes the chirp
+// it shares.
+func (db *DB) CreateChirp(chirp Chirp) (Chirp, error) {
+	err := db.Update(func(dbStructure *DBStructure) error {
+		if chirp.InReplyTo != 0 {
+			parent, ok := dbStructure.Chirps[chirp.InReplyTo]
+			if !ok || parent.DeletedAt != nil {
+				return ErrParentNotFound
+			}
+		}
+		if chirp.QuoteOf != 0 {
+			original, ok := dbStructure.originalOf(chirp.QuoteOf)
+			if !ok {
+				return ErrOriginalNotFound
+			}
+			chirp.QuoteOf = original.Id
+		}
+
+		var err error
+		chirp, err = dbStructure.insertChirp(Chirp{
+			Body:      chirp.Body,
+			AuthorID:  chirp.AuthorID,
+			InReplyTo: chirp.InReplyTo,
+			QuoteOf:   chirp.QuoteOf,
+			Mentions:  db.mentionsIn(chirp.Body),
+		})
+		return err
+	})
 	if err != nil {
-		return Chirp{}, fmt.Errorf("unable to load db: %s", err)
+		return Chirp{}, fmt.Errorf("unable to write to db: %w", err)
 	}
 
-	id := len(dbStructure.Chirps) + 1
+	return chirp, nil
+}
 
-	chirp := Chirp{
-		Id:       id,
-		Body:     body,
-		AuthorID: userID,
-	}
-	dbStructure.Chirps[id] = chirp
+// mentionsIn resolves the mentions in body. Only call it inside Update or
+// View.
+func (db *DB) mentionsIn(body string) []Mention {
+	return resolveMentions(body, func(handle string) (int, bool) {
+		id, ok := db.idx.userByHandle[handle]
+		return id, ok
+	})
+}
 
-	err = db.writeDB(dbStructure)
-	if err != nil {
-		return Chirp{}, fmt.Errorf("unable to write to db: %s", err)
+// insertChirp gives chirp an id and timestamps and stores it. Only call it
+// inside Update.
+func (dbStructure *DBStructure) insertChirp(chirp Chirp) (Chirp, error) {
+	now := time.Now()
+	chirp.Id = dbStructure.nextID(sequenceChirps)
+	chirp.Hashtags = ExtractHashtags(chirp.Body)
+	chirp.CreatedAt = now
+	chirp.UpdatedAt = now
+	chirp.Version = 1
+	dbStructure.putChirp(chirp)
+	dbStructure.countReferences(chirp, 1)
+
+	return chirp, dbStructure.recordEvent(EventChirpCreated, chirp)
+}
+
+// countReferences adds delta to the reply, rechirp and quote counts of the
+// chirps chirp refers to. Only call it inside Update.
+func (dbStructure *DBStructure) countReferences(chirp Chirp, delta int) {
+	count := func(id int, field func(*Chirp) *int) {
+		referred, ok := dbStructure.Chirps[id]
+		if id == 0 || !ok {
+			return
+		}
+
+		*field(&referred) += delta
+		referred.Version++
+		dbStructure.putChirp(referred)
 	}
 
-	return chirp, nil
+	count(chirp.InReplyTo, func(c *Chirp) *int { return &c.ReplyCount })
+	count(chirp.RechirpOf, func(c *Chirp) *int { return &c.RechirpCount })
+	count(chirp.QuoteOf, func(c *Chirp) *int { return &c.QuoteCount })
+}
+
+func (db *DB) GetChirpByID(id int) (Chirp, error) {
+	chirp := Chirp{}
+	err := db.View(func(dbStructure *DBStructure) error {
+		var ok bool
+		chirp, ok = dbStructure.Chirps[id]
+		if !ok || chirp.DeletedAt != nil {
+			return fmt.Errorf("%w: no chirp matching the id %d", ErrChirpNotFound, id)
+		}
+		return nil
+	})
+
+	return chirp, err
 }
 
-// GetChirps returns all chirps in the database
-func (db *DB) GetChirps() ([]Chirp, error) {
-	dbStructure, err := db.loadDB()
+// ListChirps returns the page of chirps selected by q
+func (db *DB) ListChirps(q ChirpQuery) ([]Chirp, error) {
+	chirps := []Chirp{}
+	err := db.View(func(dbStructure *DBStructure) error {
+		consider := func(chirp Chirp) {
+			if !q.matches(chirp) {
+				return
+			}
+			if q.ChirpyRedOnly && !dbStructure.Users[chirp.AuthorID].IsChirpyRed {
+				return
+			}
+			chirps = append(chirps, chirp)
+		}
+
+		if q.FollowedBy != 0 {
+			for authorID := range db.idx.following[q.FollowedBy] {
+				if len(q.AuthorIDs) > 0 && !slices.Contains(q.AuthorIDs, authorID) {
+					continue
+				}
+				for id := range db.idx.chirpsByAuthor[authorID] {
+					consider(dbStructure.Chirps[id])
+				}
+			}
+		} else if len(q.AuthorIDs) > 0 {
+			seen := map[int]bool{}
+			for _, authorID := range q.AuthorIDs {
+				if seen[authorID] {
+					continue
+				}
+				seen[authorID] = true
+				for id := range db.idx.chirpsByAuthor[authorID] {
+					consider(dbStructure.Chirps[id])
+				}
+			}
+		} else if q.InReplyTo != 0 {
+			for id := range db.idx.repliesByChirp[q.InReplyTo] {
+				consider(dbStructure.Chirps[id])
+			}
+		} else if q.QuoteOf != 0 {
+			for id := range db.idx.quotesOf[q.QuoteOf] {
+				consider(dbStructure.Chirps[id])
+			}
+		} else if q.Mentioning != 0 {
+			for id := range db.idx.mentionsOf[q.Mentioning] {
+				consider(dbStructure.Chirps[id])
+			}
+		} else if q.Hashtag != "" {
+			for id := range db.idx.chirpsByTag[q.Hashtag] {
+				consider(dbStructure.Chirps[id])
+			}
+		} else {
+			for _, chirp := range dbStructure.Chirps {
+				consider(chirp)
+			}
+		}
+		return nil
+	})
 	if err != nil {
 		return []Chirp{}, err
 	}
 
-	chirps := make([]Chirp, 0, len(dbStructure.Chirps))
-	for _, val := range dbStructure.Chirps {
-		chirps = append(chirps, val)
+	q.sortChirps(chirps)
+	if q.Limit > 0 && len(chirps) > q.Limit {
+		chirps = chirps[:q.Limit]
 	}
 
 	return chirps, nil
 }
 
-func (db *DB) GetChirpByID(id int) (Chirp, error) {
-	dbStructure, err := db.loadDB()
+// TrendingHashtags ranks the hashtags of the chirps created since q.Since
+func (db *DB) TrendingHashtags(q TrendingQuery) ([]TrendingHashtag, error) {
+	uses := []hashtagUse{}
+	err := db.View(func(dbStructure *DBStructure) error {
+		for _, chirp := range dbStructure.Chirps {
+			if chirp.DeletedAt != nil || !chirp.CreatedAt.After(q.Since) {
+				continue
+			}
+			for _, tag := range chirp.Hashtags {
+				uses = append(uses, hashtagUse{tag: tag, createdAt: chirp.CreatedAt})
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return Chirp{}, err
+		return []TrendingHashtag{}, err
 	}
 
-	chirp, ok := dbStructure.Chirps[id]
-	if !ok {
-		return Chirp{}, fmt.Errorf("No chirp matching the id: %d", id)
-	}
+	return rankTrending(uses, q), nil
+}
 
-	return chirp, nil
+// SearchChirps returns up to limit chirps matching q, best match first,
+// skipping the first offset
+func (db *DB) SearchChirps(q SearchQuery, offset, limit int) ([]Chirp, error) {
+	chirps := []Chirp{}
+	err := db.View(func(dbStructure *DBStructure) error {
+		ids := db.idx.search.search(q)
+		for i := offset; i < len(ids) && len(chirps) < limit; i++ {
+			chirps = append(chirps, dbStructure.Chirps[ids[i]])
+		}
+		return nil
+	})
+
+	return chirps, err
 }
 
-func (db *DB) GetChirpsByAuthor(id int) ([]Chirp, error) {
-	dbStructure, err := db.loadDB()
-	if err != nil {
-		return []Chirp{}, err
-	}
+// DeleteChirpByID moves a chirp and its rechirps to the trash, they can be
+// brought back with RestoreChirpByID until they are purged
+func (db *DB) DeleteChirpByID(id, version int) error {
+	return db.Update(func(dbStructure *DBStructure) error {
+		chirp, ok := dbStructure.Chirps[id]
+		if !ok || chirp.DeletedAt != nil {
+			return fmt.Errorf("%w: unable to find chirp with provided id", ErrChirpNotFound)
+		}
+		if !versionMatches(version, chirp.Version) {
+			return ErrVersionMismatch
+		}
 
-	chirps := make([]Chirp, 0)
-	for _, val := range dbStructure.Chirps {
-		if val.AuthorID == id {
-			chirps = append(chirps, val)
+		now := time.Now()
+		for rechirpID := range db.idx.rechirpsOf[id] {
+			if rechirp := dbStructure.Chirps[rechirpID]; rechirp.DeletedAt == nil {
+				err := dbStructure.trashChirp(rechirp, now)
+				if err != nil {
+					return err
+				}
+			}
 		}
-	}
 
-	if len(chirps) == 0 {
-		return chirps, fmt.Errorf("no chirps matching that author_id")
-	}
+		// the rechirps changed the count and version
+		return dbStructure.trashChirp(dbStructure.Chirps[id], now)
+	})
+}
 
-	return chirps, nil
+// trashChirp sets the DeletedAt of chirp. Only call it inside Update.
+func (dbStructure *DBStructure) trashChirp(chirp Chirp, now time.Time) error {
+	chirp.DeletedAt = &now
+	chirp.UpdatedAt = now
+	chirp.Version++
+	dbStructure.putChirp(chirp)
+	dbStructure.countReferences(chirp, -1)
+	return dbStructure.recordEvent(EventChirpDeleted, chirp)
 }
 
-func (db *DB) DeleteChirpByID(id int) error {
-	dbStructure, err := db.loadDB()
-	if err != nil {
-		return err
-	}
+// GetDeletedChirpByID returns a chirp that is in the trash
+func (db *DB) GetDeletedChirpByID(id int) (Chirp, error) {
+	chirp := Chirp{}
+	err := db.View(func(dbStructure *DBStructure) error {
+		var ok bool
+		chirp, ok = dbStructure.Chirps[id]
+		if !ok {
+			return fmt.Errorf("%w: no chirp matching the id %d", ErrChirpNotFound, id)
+		}
+		if chirp.DeletedAt == nil {
+			return ErrChirpNotDeleted
+		}
+		return nil
+	})
 
-	_, ok := dbStructure.Chirps[id]
-	if !ok {
-		return fmt.Errorf("unable to find chirp with provided id")
-	}
+	return chirp, err
+}
 
-	delete(dbStructure.Chirps, id)
+// RestoreChirpByID takes a chirp out of the trash, provided it was deleted
+// after deletedSince, along with the rechirps that were trashed with it. A
+// rechirp can only come back while the chirp it shares is there and the
+// author has not rechirped it again.
+func (db *DB) RestoreChirpByID(id int, deletedSince time.Time) (Chirp, error) {
+	chirp := Chirp{}
+	err := db.Update(func(dbStructure *DBStructure) error {
+		var ok bool
+		chirp, ok = dbStructure.Chirps[id]
+		if !ok {
+			return fmt.Errorf("%w: no chirp matching the id %d", ErrChirpNotFound, id)
+		}
+		if chirp.DeletedAt == nil {
+			return ErrChirpNotDeleted
+		}
+		if chirp.DeletedAt.Before(deletedSince) {
+			return ErrRestoreWindowExpired
+		}
+		if chirp.RechirpOf != 0 {
+			if original, ok := dbStructure.Chirps[chirp.RechirpOf]; !ok || original.DeletedAt != nil {
+				return ErrOriginalNotFound
+			}
+			if _, ok := db.rechirpBy(dbStructure, chirp.RechirpOf, chirp.AuthorID); ok {
+				return ErrAlreadyRechirped
+			}
+		}
 
-	for key, val := range dbStructure.Chirps {
-		if key > id {
-			dbStructure.Chirps[key-1] = val
-			delete(dbStructure.Chirps, key)
+		now := time.Now()
+		for rechirpID := range db.idx.rechirpsOf[id] {
+			rechirp := dbStructure.Chirps[rechirpID]
+			if rechirp.DeletedAt != nil && rechirp.DeletedAt.Equal(*chirp.DeletedAt) {
+				err := dbStructure.untrashChirp(rechirp, now)
+				if err != nil {
+					return err
+				}
+			}
 		}
-	}
 
-	err = db.writeDB(dbStructure)
-	if err != nil {
+		err := dbStructure.untrashChirp(dbStructure.Chirps[id], now)
+		chirp = dbStructure.Chirps[id]
 		return err
+	})
+
+	return chirp, err
+}
+
+// untrashChirp clears the DeletedAt of chirp. Only call it inside Update.
+func (dbStructure *DBStructure) untrashChirp(chirp Chirp, now time.Time) error {
+	chirp.DeletedAt = nil
+	chirp.UpdatedAt = now
+	chirp.Version++
+	dbStructure.putChirp(chirp)
+	dbStructure.countReferences(chirp, 1)
+	return dbStructure.recordEvent(EventChirpRestored, chirp)
+}
+
+// PurgeDeletedChirps permanently removes chirps deleted before
+// deletedBefore and returns how many were removed
+func (db *DB) PurgeDeletedChirps(deletedBefore time.Time) (int, error) {
+	purged := 0
+	err := db.Update(func(dbStructure *DBStructure) error {
+		for id, chirp := range dbStructure.Chirps {
+			if chirp.DeletedAt != nil && chirp.DeletedAt.Before(deletedBefore) {
+				dbStructure.deleteChirp(id)
+				dbStructure.deleteRevisions(id)
+				for userID := range db.idx.likesByChirp[id] {
+					dbStructure.deleteLike(likeKey(id, userID))
+				}
+				purged++
+				err := dbStructure.recordEvent(EventChirpPurged, chirp)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
 	}
 
-	return nil
+	return purged, nil
+}
+
+// FlagChirp queues chirp id for review by moderators, as a chirp.flagged
+// event naming the rules that caught it
+func (db *DB) FlagChirp(id int, rules []string) error {
+	return db.Update(func(dbStructure *DBStructure) error {
+		chirp, ok := dbStructure.Chirps[id]
+		if !ok || chirp.DeletedAt != nil {
+			return fmt.Errorf("%w: no chirp matching the id %d", ErrChirpNotFound, id)
+		}
+
+		return dbStructure.recordEvent(EventChirpFlagged, flagEvent(chirp, rules))
+	})
 }
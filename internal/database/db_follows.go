@@ -0,0 +1,119 @@
+package database
+
+import (
+	"sort"
+	"strconv"
+	"time"
+)
+
+func followKey(followerID, followeeID int) string {
+	return strconv.Itoa(followerID) + ":" + strconv.Itoa(followeeID)
+}
+
+// Follow makes followerID follow followeeID, and reports false if they
+// already did
+func (db *DB) Follow(followerID, followeeID int) (bool, error) {
+	if followerID == followeeID {
+		return false, ErrFollowSelf
+	}
+
+	followed := false
+	err := db.Update(func(dbStructure *DBStructure) error {
+		if _, ok := dbStructure.Users[followeeID]; !ok {
+			return ErrUserNotFound
+		}
+
+		key := followKey(followerID, followeeID)
+		if _, ok := dbStructure.Follows[key]; ok {
+			return nil
+		}
+
+		follow := Follow{
+			FollowerID: followerID,
+			FolloweeID: followeeID,
+			CreatedAt:  time.Now(),
+		}
+		dbStructure.putFollow(follow)
+
+		followed = true
+		return dbStructure.recordEvent(EventUserFollowed, follow)
+	})
+
+	return followed, err
+}
+
+// Unfollow stops followerID following followeeID, and reports false if
+// they did not
+func (db *DB) Unfollow(followerID, followeeID int) (bool, error) {
+	unfollowed := false
+	err := db.Update(func(dbStructure *DBStructure) error {
+		if _, ok := dbStructure.Users[followeeID]; !ok {
+			return ErrUserNotFound
+		}
+
+		key := followKey(followerID, followeeID)
+		follow, ok := dbStructure.Follows[key]
+		if !ok {
+			return nil
+		}
+		dbStructure.deleteFollow(key)
+
+		unfollowed = true
+		return dbStructure.recordEvent(EventUserUnfollowed, follow)
+	})
+
+	return unfollowed, err
+}
+
+// GetFollowers returns up to limit follows of userID, newest first,
+// skipping the first offset
+func (db *DB) GetFollowers(userID, offset, limit int) ([]Follow, error) {
+	return db.getFollows(userID, offset, limit, true)
+}
+
+// GetFollowing returns up to limit follows by userID, newest first,
+// skipping the first offset
+func (db *DB) GetFollowing(userID, offset, limit int) ([]Follow, error) {
+	return db.getFollows(userID, offset, limit, false)
+}
+
+// getFollows pages through the follows of userID when followers is set,
+// and those by userID otherwise
+func (db *DB) getFollows(userID, offset, limit int, followers bool) ([]Follow, error) {
+	follows := []Follow{}
+	err := db.View(func(dbStructure *DBStructure) error {
+		if _, ok := dbStructure.Users[userID]; !ok {
+			return ErrUserNotFound
+		}
+
+		if followers {
+			for followerID := range db.idx.followers[userID] {
+				follows = append(follows, dbStructure.Follows[followKey(followerID, userID)])
+			}
+		} else {
+			for followeeID := range db.idx.following[userID] {
+				follows = append(follows, dbStructure.Follows[followKey(userID, followeeID)])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return []Follow{}, err
+	}
+
+	// the user on the other end breaks ties
+	other := func(follow Follow) int {
+		if followers {
+			return follow.FollowerID
+		}
+		return follow.FolloweeID
+	}
+	sort.Slice(follows, func(i, j int) bool {
+		if !follows[i].CreatedAt.Equal(follows[j].CreatedAt) {
+			return follows[i].CreatedAt.After(follows[j].CreatedAt)
+		}
+		return other(follows[i]) > other(follows[j])
+	})
+
+	return page(follows, offset, limit), nil
+}
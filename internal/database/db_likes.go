@@ -0,0 +1,144 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+func likeKey(chirpID, userID int) string {
+	return strconv.Itoa(chirpID) + ":" + strconv.Itoa(userID)
+}
+
+// LikeChirp records that userID likes chirpID, and reports false if they
+// already did
+func (db *DB) LikeChirp(chirpID, userID int) (bool, error) {
+	liked := false
+	err := db.Update(func(dbStructure *DBStructure) error {
+		chirp, ok := dbStructure.Chirps[chirpID]
+		if !ok || chirp.DeletedAt != nil {
+			return fmt.Errorf("%w: no chirp matching the id %d", ErrChirpNotFound, chirpID)
+		}
+
+		key := likeKey(chirpID, userID)
+		if _, ok := dbStructure.Likes[key]; ok {
+			return nil
+		}
+
+		like := Like{
+			ChirpID:   chirpID,
+			UserID:    userID,
+			CreatedAt: time.Now(),
+		}
+		dbStructure.putLike(like)
+
+		chirp.LikeCount++
+		chirp.Version++
+		dbStructure.putChirp(chirp)
+
+		liked = true
+		return dbStructure.recordEvent(EventChirpLiked, like)
+	})
+
+	return liked, err
+}
+
+// UnlikeChirp removes the like of userID from chirpID, and reports false if
+// there was none
+func (db *DB) UnlikeChirp(chirpID, userID int) (bool, error) {
+	unliked := false
+	err := db.Update(func(dbStructure *DBStructure) error {
+		chirp, ok := dbStructure.Chirps[chirpID]
+		if !ok || chirp.DeletedAt != nil {
+			return fmt.Errorf("%w: no chirp matching the id %d", ErrChirpNotFound, chirpID)
+		}
+
+		key := likeKey(chirpID, userID)
+		like, ok := dbStructure.Likes[key]
+		if !ok {
+			return nil
+		}
+		dbStructure.deleteLike(key)
+
+		chirp.LikeCount--
+		chirp.Version++
+		dbStructure.putChirp(chirp)
+
+		unliked = true
+		return dbStructure.recordEvent(EventChirpUnliked, like)
+	})
+
+	return unliked, err
+}
+
+// GetChirpLikes returns up to limit likes of a chirp, newest first,
+// skipping the first offset
+func (db *DB) GetChirpLikes(chirpID, offset, limit int) ([]Like, error) {
+	likes := []Like{}
+	err := db.View(func(dbStructure *DBStructure) error {
+		chirp, ok := dbStructure.Chirps[chirpID]
+		if !ok || chirp.DeletedAt != nil {
+			return fmt.Errorf("%w: no chirp matching the id %d", ErrChirpNotFound, chirpID)
+		}
+
+		for userID := range db.idx.likesByChirp[chirpID] {
+			likes = append(likes, dbStructure.Likes[likeKey(chirpID, userID)])
+		}
+		return nil
+	})
+	if err != nil {
+		return []Like{}, err
+	}
+
+	sort.Slice(likes, func(i, j int) bool {
+		if !likes[i].CreatedAt.Equal(likes[j].CreatedAt) {
+			return likes[i].CreatedAt.After(likes[j].CreatedAt)
+		}
+		return likes[i].UserID > likes[j].UserID
+	})
+
+	return page(likes, offset, limit), nil
+}
+
+// GetLikedChirps returns up to limit chirps userID likes, most recently
+// liked first, skipping the first offset
+func (db *DB) GetLikedChirps(userID, offset, limit int) ([]Chirp, error) {
+	likes := []Like{}
+	chirps := map[int]Chirp{}
+	err := db.View(func(dbStructure *DBStructure) error {
+		for chirpID := range db.idx.likesByUser[userID] {
+			chirp := dbStructure.Chirps[chirpID]
+			if chirp.DeletedAt == nil {
+				likes = append(likes, dbStructure.Likes[likeKey(chirpID, userID)])
+				chirps[chirpID] = chirp
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return []Chirp{}, err
+	}
+
+	sort.Slice(likes, func(i, j int) bool {
+		if !likes[i].CreatedAt.Equal(likes[j].CreatedAt) {
+			return likes[i].CreatedAt.After(likes[j].CreatedAt)
+		}
+		return likes[i].ChirpID > likes[j].ChirpID
+	})
+
+	liked := []Chirp{}
+	for _, like := range page(likes, offset, limit) {
+		liked = append(liked, chirps[like.ChirpID])
+	}
+
+	return liked, nil
+}
+
+// page returns up to limit items of s starting at offset
+func page[T any](s []T, offset, limit int) []T {
+	if offset >= len(s) {
+		return []T{}
+	}
+	return s[offset:min(len(s), offset+limit)]
+}
@@ -0,0 +1,84 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// Rechirp shares chirpID on behalf of userID. Rechirping a rechirp shares
+// the chirp it shares. A user rechirps a chirp at most once, when they
+// already did their rechirp is returned and created is false.
+func (db *DB) Rechirp(chirpID, userID int) (rechirp Chirp, created bool, err error) {
+	err = db.Update(func(dbStructure *DBStructure) error {
+		original, ok := dbStructure.originalOf(chirpID)
+		if !ok {
+			return fmt.Errorf("%w: no chirp matching the id %d", ErrChirpNotFound, chirpID)
+		}
+
+		if rechirp, ok = db.rechirpBy(dbStructure, original.Id, userID); ok {
+			return nil
+		}
+
+		var err error
+		rechirp, err = dbStructure.insertChirp(Chirp{
+			AuthorID:  userID,
+			RechirpOf: original.Id,
+		})
+		created = true
+		return err
+	})
+	if err != nil {
+		return Chirp{}, false, err
+	}
+
+	return rechirp, created, nil
+}
+
+// Unrechirp moves the rechirp of chirpID by userID to the trash, and
+// reports false if there was none
+func (db *DB) Unrechirp(chirpID, userID int) (bool, error) {
+	deleted := false
+	err := db.Update(func(dbStructure *DBStructure) error {
+		original, ok := dbStructure.originalOf(chirpID)
+		if !ok {
+			return fmt.Errorf("%w: no chirp matching the id %d", ErrChirpNotFound, chirpID)
+		}
+
+		rechirp, ok := db.rechirpBy(dbStructure, original.Id, userID)
+		if !ok {
+			return nil
+		}
+
+		deleted = true
+		return dbStructure.trashChirp(rechirp, time.Now())
+	})
+
+	return deleted, err
+}
+
+// originalOf returns the chirp that id shares when it is a rechirp, and
+// the chirp itself otherwise, if it has not been deleted
+func (dbStructure *DBStructure) originalOf(id int) (Chirp, bool) {
+	chirp, ok := dbStructure.Chirps[id]
+	if ok && chirp.RechirpOf != 0 {
+		chirp, ok = dbStructure.Chirps[chirp.RechirpOf]
+	}
+	if !ok || chirp.DeletedAt != nil {
+		return Chirp{}, false
+	}
+
+	return chirp, true
+}
+
+// rechirpBy finds the rechirp of originalID by userID that is not in the
+// trash
+func (db *DB) rechirpBy(dbStructure *DBStructure, originalID, userID int) (Chirp, bool) {
+	for id := range db.idx.rechirpsOf[originalID] {
+		chirp := dbStructure.Chirps[id]
+		if chirp.AuthorID == userID && chirp.DeletedAt == nil {
+			return chirp, true
+		}
+	}
+
+	return Chirp{}, false
+}
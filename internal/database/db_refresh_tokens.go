@@ -6,60 +6,77 @@ import (
 )
 
 func (db *DB) StoreRefreshToken(token string, userID int) error {
-	dbStructure, err := db.loadDB()
-	if err != nil {
-		return err
-	}
+	return db.Update(func(dbStructure *DBStructure) error {
+		dbStructure.putRefreshToken(RefreshToken{
+			UserID:    userID,
+			Token:     token,
+			ExpiresAt: time.Now().Add(time.Hour),
+		})
+		return nil
+	})
+}
 
-	refreshToken := RefreshToken{
-		UserID:    userID,
-		Token:     token,
-		ExpiresAt: time.Now().Add(time.Hour),
-	}
-	dbStructure.RefreshTokens[token] = refreshToken
+func (db *DB) RevokeRefreshToken(token string) error {
+	err := db.Update(func(dbStructure *DBStructure) error {
+		refreshToken, ok := dbStructure.RefreshTokens[token]
+		if !ok || refreshToken.RevokedAt != nil {
+			return nil
+		}
 
-	err = db.writeDB(dbStructure)
+		now := time.Now()
+		refreshToken.RevokedAt = &now
+		dbStructure.putRefreshToken(refreshToken)
+		return dbStructure.recordEvent(EventTokenRevoked, tokenEventData{UserID: refreshToken.UserID})
+	})
 	if err != nil {
-		return err
+		return fmt.Errorf("unable to write revoked token to db")
 	}
+
 	return nil
 }
 
-func (db *DB) RevokeRefreshToken(token string) error {
-    dbStructure, err := db.loadDB()
-    if err != nil {
-        return err
-    }
-    delete(dbStructure.RefreshTokens, token)
-    
+func (db *DB) GetUserByRefreshToken(tokenString string) (User, error) {
+	user := User{}
+	err := db.View(func(dbStructure *DBStructure) error {
+		refreshToken, ok := dbStructure.RefreshTokens[tokenString]
+		if !ok {
+			return fmt.Errorf("Token does not exist")
+		}
 
-    err = db.writeDB(dbStructure)
-    if err != nil {
-        return fmt.Errorf("unable to write revoked token to db")
-    }
-    
-    return nil
-}
+		if refreshToken.RevokedAt != nil {
+			return fmt.Errorf("token has been revoked")
+		}
 
-func (db *DB) GetUserByRefreshToken(tokenString string) (User, error) {
-	dbStructure, err := db.loadDB()
-	if err != nil {
-		return User{}, err
-	}
+		if refreshToken.ExpiresAt.Before(time.Now()) {
+			return fmt.Errorf("token has expired")
+		}
 
-	refreshToken, ok := dbStructure.RefreshTokens[tokenString]
-	if !ok {
-		return User{}, fmt.Errorf("Token does not exist")
-	}
+		user, ok = dbStructure.Users[refreshToken.UserID]
+		if !ok {
+			return ErrUserNotFound
+		}
+		return nil
+	})
 
-	if refreshToken.ExpiresAt.Before(time.Now()) {
-		return User{}, fmt.Errorf("token has expired")
-	}
+	return user, err
+}
 
-	user, err := db.GetUserByID(refreshToken.UserID)
+// PurgeStaleRefreshTokens deletes every refresh token that has expired or
+// been revoked as of now and returns how many were removed
+func (db *DB) PurgeStaleRefreshTokens(now time.Time) (int, error) {
+	purged := 0
+	err := db.Update(func(dbStructure *DBStructure) error {
+		for token, refreshToken := range dbStructure.RefreshTokens {
+			if refreshToken.RevokedAt != nil || refreshToken.ExpiresAt.Before(now) {
+				dbStructure.deleteRefreshToken(token)
+				purged++
+			}
+		}
+		return nil
+	})
 	if err != nil {
-		return User{}, err
+		return 0, err
 	}
 
-	return user, nil
+	return purged, nil
 }
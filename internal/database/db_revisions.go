@@ -0,0 +1,65 @@
+package database
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// EditChirp replaces the body of a chirp, keeping the old one as a
+// revision
+func (db *DB) EditChirp(id, version int, body string) (Chirp, error) {
+	chirp := Chirp{}
+	err := db.Update(func(dbStructure *DBStructure) error {
+		var ok bool
+		chirp, ok = dbStructure.Chirps[id]
+		if !ok || chirp.DeletedAt != nil {
+			return fmt.Errorf("%w: no chirp matching the id %d", ErrChirpNotFound, id)
+		}
+		if !versionMatches(version, chirp.Version) {
+			return ErrVersionMismatch
+		}
+		if chirp.RechirpOf != 0 {
+			return ErrRechirpNotEditable
+		}
+
+		revisions := dbStructure.Revisions[id]
+		dbStructure.putRevisions(id, append(slices.Clip(revisions), ChirpRevision{
+			Revision:  len(revisions) + 1,
+			Body:      chirp.Body,
+			CreatedAt: chirp.bodyWrittenAt(),
+		}))
+
+		now := time.Now()
+		chirp.Body = body
+		chirp.Hashtags = ExtractHashtags(body)
+		chirp.Mentions = db.mentionsIn(body)
+		chirp.EditedAt = &now
+		chirp.UpdatedAt = now
+		chirp.Version++
+		dbStructure.putChirp(chirp)
+
+		return dbStructure.recordEvent(EventChirpEdited, chirp)
+	})
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	return chirp, nil
+}
+
+// GetChirpRevisions returns the earlier bodies of a chirp, oldest first
+func (db *DB) GetChirpRevisions(id int) ([]ChirpRevision, error) {
+	revisions := []ChirpRevision{}
+	err := db.View(func(dbStructure *DBStructure) error {
+		chirp, ok := dbStructure.Chirps[id]
+		if !ok || chirp.DeletedAt != nil {
+			return fmt.Errorf("%w: no chirp matching the id %d", ErrChirpNotFound, id)
+		}
+
+		revisions = append(revisions, dbStructure.Revisions[id]...)
+		return nil
+	})
+
+	return revisions, err
+}
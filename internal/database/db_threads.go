@@ -0,0 +1,52 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+)
+
+// GetThread returns the conversation chirp id belongs to: the chirp at the
+// top of its reply chain and up to limit-1 of the replies below it, deleted
+// ones included so the tree can be rebuilt. Chirps are ordered by depth,
+// then by the chirp they reply to, then by id.
+func (db *DB) GetThread(id, limit int) ([]Chirp, error) {
+	thread := []Chirp{}
+	err := db.View(func(dbStructure *DBStructure) error {
+		chirp, ok := dbStructure.Chirps[id]
+		if !ok || chirp.DeletedAt != nil {
+			return fmt.Errorf("%w: no chirp matching the id %d", ErrChirpNotFound, id)
+		}
+
+		// purged chirps end the chain early
+		for chirp.InReplyTo != 0 {
+			parent, ok := dbStructure.Chirps[chirp.InReplyTo]
+			if !ok {
+				break
+			}
+			chirp = parent
+		}
+
+		// level by level, each ordered by parent then id
+		level := []Chirp{chirp}
+		for len(level) > 0 && len(thread) < limit {
+			thread = append(thread, level[:min(len(level), limit-len(thread))]...)
+
+			next := []Chirp{}
+			for _, parent := range level {
+				for replyID := range db.idx.repliesByChirp[parent.Id] {
+					next = append(next, dbStructure.Chirps[replyID])
+				}
+			}
+			sort.Slice(next, func(i, j int) bool {
+				if next[i].InReplyTo != next[j].InReplyTo {
+					return next[i].InReplyTo < next[j].InReplyTo
+				}
+				return next[i].Id < next[j].Id
+			})
+			level = next
+		}
+		return nil
+	})
+
+	return thread, err
+}
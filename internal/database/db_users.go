@@ -4,29 +4,31 @@ import (
 	"fmt"
 )
 
-func (db *DB) CreateUsers(email, password string) (User, error) {
-	dbStructure, err := db.loadDB()
-	if err != nil {
-		return User{}, err
-	}
-
-	_, ok := searchUserByEmail(dbStructure, email)
-	if ok {
-		return User{}, fmt.Errorf("user with email already exists")
-
-	}
-
-	userID := len(dbStructure.Users) + 1
-	user := User{
-		Id:          userID,
-		Email:       email,
-		Password:    password,
-		IsChirpyRed: false,
-	}
+// CreateUsers stores a new user from the Email, Password and Handle of
+// user, the handle is optional
+func (db *DB) CreateUsers(user User) (User, error) {
+	err := db.Update(func(dbStructure *DBStructure) error {
+		_, ok := db.idx.userByEmail[user.Email]
+		if ok {
+			return fmt.Errorf("user with email already exists")
+		}
+		if _, ok := db.idx.userByHandle[user.Handle]; ok && user.Handle != "" {
+			return ErrHandleTaken
+		}
 
-	dbStructure.Users[userID] = user
+		userID := dbStructure.nextID(sequenceUsers)
+		user = User{
+			Id:          userID,
+			Email:       user.Email,
+			Handle:      user.Handle,
+			Password:    user.Password,
+			IsChirpyRed: false,
+			Version:     1,
+		}
 
-	err = db.writeDB(dbStructure)
+		dbStructure.putUser(user)
+		return dbStructure.recordEvent(EventUserCreated, userEvent(user))
+	})
 	if err != nil {
 		return User{}, err
 	}
@@ -35,95 +37,103 @@ func (db *DB) CreateUsers(email, password string) (User, error) {
 }
 
 func (db *DB) GetUserByID(id int) (User, error) {
-	dbStructure, err := db.loadDB()
-	if err != nil {
-		return User{}, err
-	}
-
-	user, ok := dbStructure.Users[id]
-	if !ok {
-		return User{}, fmt.Errorf("user does not exist")
-	}
+	user := User{}
+	err := db.View(func(dbStructure *DBStructure) error {
+		var ok bool
+		user, ok = dbStructure.Users[id]
+		if !ok {
+			return ErrUserNotFound
+		}
+		return nil
+	})
 
-	return user, nil
+	return user, err
 }
 
-func (db *DB) UpdateUserLogin(email, password string, id int) (User, error) {
-	dbStructure, err := db.loadDB()
-	if err != nil {
-		return User{}, err
-	}
-
-	user, ok := dbStructure.Users[id]
-	if !ok {
-		return User{}, fmt.Errorf("user does not exist")
-	}
+// UpdateUser replaces the email, password, handle and profile of the user
+// with the id of user by those of user
+func (db *DB) UpdateUser(user User, version int) (User, error) {
+	updatedUser := User{}
+	err := db.Update(func(dbStructure *DBStructure) error {
+		current, ok := dbStructure.Users[user.Id]
+		if !ok {
+			return ErrUserNotFound
+		}
+		if !versionMatches(version, current.Version) {
+			return ErrVersionMismatch
+		}
 
-	updatedUser := User{
-		Id:          user.Id,
-		Email:       email,
-		Password:    password,
-		Token:       user.Token,
-		IsChirpyRed: user.IsChirpyRed,
-	}
+		otherID, ok := db.idx.userByEmail[user.Email]
+		if ok && otherID != user.Id {
+			return fmt.Errorf("user with email already exists")
+		}
+		otherID, ok = db.idx.userByHandle[user.Handle]
+		if ok && otherID != user.Id && user.Handle != "" {
+			return ErrHandleTaken
+		}
 
-	dbStructure.Users[id] = updatedUser
+		updatedUser = User{
+			Id:          current.Id,
+			Email:       user.Email,
+			Handle:      user.Handle,
+			DisplayName: user.DisplayName,
+			Bio:         user.Bio,
+			AvatarURL:   user.AvatarURL,
+			Password:    user.Password,
+			Token:       current.Token,
+			IsChirpyRed: current.IsChirpyRed,
+			Version:     current.Version + 1,
+		}
 
-	err = db.writeDB(dbStructure)
+		dbStructure.putUser(updatedUser)
+		return dbStructure.recordEvent(EventUserUpdated, userEvent(updatedUser))
+	})
 	if err != nil {
 		return User{}, err
 	}
+
 	return updatedUser, nil
 }
 
 func (db *DB) GetUserByEmail(email string) (User, error) {
-	dbStructure, err := db.loadDB()
-	if err != nil {
-		return User{}, err
-	}
-
-	user, ok := searchUserByEmail(dbStructure, email)
-	if !ok {
-		return User{}, fmt.Errorf("user does not exist")
-	}
+	user := User{}
+	err := db.View(func(dbStructure *DBStructure) error {
+		id, ok := db.idx.userByEmail[email]
+		if !ok {
+			return ErrUserNotFound
+		}
+		user = dbStructure.Users[id]
+		return nil
+	})
 
-	return user, nil
+	return user, err
 }
 
-func (db *DB) UpgradeUser(userID int) error {
-	dbStructure, err := db.loadDB()
-	if err != nil {
-		return err
-	}
-
-	user, ok := dbStructure.Users[userID]
-	if !ok {
-		return fmt.Errorf("user does not exist")
-	}
-
-	updatedUser := User{
-		Id:          user.Id,
-		Email:       user.Email,
-		Password:    user.Password,
-		Token:       user.Email,
-		IsChirpyRed: true,
-	}
-
-	dbStructure.Users[userID] = updatedUser
-
-	err = db.writeDB(dbStructure)
-	if err != nil {
-		return err
-	}
+func (db *DB) GetUserByHandle(handle string) (User, error) {
+	user := User{}
+	err := db.View(func(dbStructure *DBStructure) error {
+		id, ok := db.idx.userByHandle[handle]
+		if !ok {
+			return ErrUserNotFound
+		}
+		user = dbStructure.Users[id]
+		return nil
+	})
 
-	return nil
+	return user, err
 }
 
-func searchUserByEmail(dbStructure DBStructure, email string) (User, bool) {
-	for _, user := range dbStructure.Users {
-		if user.Email == email {
-			return user, true
+func (db *DB) UpgradeUser(userID int) error {
+	return db.Update(func(dbStructure *DBStructure) error {
+		user, ok := dbStructure.Users[userID]
+		if !ok {
+			return ErrUserNotFound
 		}
-	}
-	return User{}, false
+
+		user.IsChirpyRed = true
+		user.Version++
+		dbStructure.putUser(user)
+
+		return dbStructure.recordEvent(EventUserUpgraded, userEvent(user))
+	})
 }
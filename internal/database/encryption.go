@@ -0,0 +1,252 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Encrypted files are sealed with AES-256-GCM:
+//
+//	"CHIRPYENC1" | key id (16 hex chars) | nonce (12 bytes) | ciphertext
+//
+// The key id is a fingerprint of the key, letting a wrong key be reported
+// as such instead of as a generic authentication failure. The header is
+// authenticated along with the data.
+
+const (
+	encryptionMagic = "CHIRPYENC1"
+	keyIDLen        = 16
+	keyLen          = 32
+)
+
+var (
+	ErrWrongKey               = errors.New("wrong encryption key")
+	ErrEncryptionKeyRequired  = errors.New("db is encrypted but no encryption key was configured")
+	ErrEncryptionNotSupported = errors.New("encryption at rest is only supported by the json store")
+)
+
+type cipherKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// ParseEncryptionKey decodes a base64 encoded 32 byte key, surrounding
+// whitespace is ignored so key files can end in a newline
+func ParseEncryptionKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("encryption key is not valid base64: %s", err)
+	}
+	if len(key) != keyLen {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", keyLen, len(key))
+	}
+
+	return key, nil
+}
+
+func newCipherKey(key []byte) (*cipherKey, error) {
+	if key == nil {
+		return nil, nil
+	}
+	if len(key) != keyLen {
+		return nil, fmt.Errorf("encryption key must be %d bytes, got %d", keyLen, len(key))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(key)
+	return &cipherKey{
+		id:   hex.EncodeToString(sum[:])[:keyIDLen],
+		aead: aead,
+	}, nil
+}
+
+func isSealed(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptionMagic))
+}
+
+func (k *cipherKey) seal(plaintext []byte) ([]byte, error) {
+	header := []byte(encryptionMagic + k.id)
+	nonce := make([]byte, k.aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := append(header, nonce...)
+	return k.aead.Seal(sealed, nonce, plaintext, header), nil
+}
+
+func (k *cipherKey) open(data []byte) ([]byte, error) {
+	headerLen := len(encryptionMagic) + keyIDLen
+	if !isSealed(data) || len(data) < headerLen+k.aead.NonceSize() {
+		return nil, errors.New("not an encrypted chirpy file")
+	}
+
+	header := data[:headerLen]
+	id := string(header[len(encryptionMagic):])
+	if id != k.id {
+		return nil, fmt.Errorf("%w: data was encrypted with key %s, configured key is %s", ErrWrongKey, id, k.id)
+	}
+
+	nonce := data[headerLen : headerLen+k.aead.NonceSize()]
+	plaintext, err := k.aead.Open(nil, nonce, data[headerLen+k.aead.NonceSize():], header)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrWrongKey, err)
+	}
+
+	return plaintext, nil
+}
+
+// decode returns the plaintext of data, which may or may not be sealed.
+// Sealed data without a key is an error; plain data is passed through.
+func (k *cipherKey) decode(data []byte) ([]byte, error) {
+	if !isSealed(data) {
+		return data, nil
+	}
+	if k == nil {
+		return nil, ErrEncryptionKeyRequired
+	}
+	return k.open(data)
+}
+
+// encode seals data when there is a key, and passes it through otherwise
+func (k *cipherKey) encode(data []byte) ([]byte, error) {
+	if k == nil {
+		return data, nil
+	}
+	return k.seal(data)
+}
+
+// RotateEncryptionKey rewrites the JSON database at path and the backups
+// taken next to it before migrations under newKey. A nil oldKey encrypts a
+// plaintext database, a nil newKey decrypts one. Stop the server first.
+func RotateEncryptionKey(path string, oldKey, newKey []byte) error {
+	db, err := NewDBWithOptions(path, Options{EncryptionKey: oldKey})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	key, err := newCipherKey(newKey)
+	if err != nil {
+		return err
+	}
+
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	oldCipher := db.key
+	db.key = key
+	err = db.checkpoint()
+	if err != nil {
+		return err
+	}
+
+	// the backups taken before migrations are sealed with the old key too,
+	// they would be unreadable once it is retired
+	backups, err := migrationBackups(path)
+	if err != nil {
+		return err
+	}
+	for _, backup := range backups {
+		data, err := os.ReadFile(backup)
+		if err != nil {
+			return err
+		}
+		plaintext, err := oldCipher.decode(data)
+		if err != nil {
+			return fmt.Errorf("unable to decrypt migration backup %s: %w", backup, err)
+		}
+		encoded, err := key.encode(plaintext)
+		if err != nil {
+			return err
+		}
+		err = writeFileAtomic(backup, encoded, 0600)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ReencryptBackups rewrites every backup in dir from oldKey to newKey and
+// returns how many were rewritten, see RotateEncryptionKey
+func ReencryptBackups(dir string, oldKey, newKey []byte) (int, error) {
+	oldCipher, err := newCipherKey(oldKey)
+	if err != nil {
+		return 0, err
+	}
+	newCipher, err := newCipherKey(newKey)
+	if err != nil {
+		return 0, err
+	}
+
+	backups, err := ListBackups(dir)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, backup := range backups {
+		path := filepath.Join(dir, backup.Name)
+		compressed, err := os.ReadFile(path)
+		if err != nil {
+			return 0, err
+		}
+
+		gz, err := gzip.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return 0, fmt.Errorf("unable to read backup %s: %s", backup.Name, err)
+		}
+		data := bytes.Buffer{}
+		_, err = data.ReadFrom(gz)
+		if err != nil {
+			return 0, fmt.Errorf("unable to read backup %s: %s", backup.Name, err)
+		}
+
+		plaintext, err := oldCipher.decode(data.Bytes())
+		if err != nil {
+			return 0, fmt.Errorf("unable to decrypt backup %s: %w", backup.Name, err)
+		}
+		encoded, err := newCipher.encode(plaintext)
+		if err != nil {
+			return 0, err
+		}
+
+		out := bytes.Buffer{}
+		gzOut := gzip.NewWriter(&out)
+		_, err = gzOut.Write(encoded)
+		if err == nil {
+			err = gzOut.Close()
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		err = writeFileAtomic(path, out.Bytes(), 0600)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(backups), nil
+}
@@ -0,0 +1,136 @@
+package database
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testKey(b byte) []byte {
+	key := make([]byte, keyLen)
+	for i := range key {
+		key[i] = b
+	}
+	return key
+}
+
+func TestOpenEncryptedDBWithWrongKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database.json")
+	db, err := NewDBWithOptions(path, Options{EncryptionKey: testKey(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	createUsers(t, db, 1)
+	db.Close()
+
+	_, err = NewDBWithOptions(path, Options{EncryptionKey: testKey(2)})
+	if !errors.Is(err, ErrWrongKey) {
+		t.Errorf("got %v opening with the wrong key, want ErrWrongKey", err)
+	}
+	_, err = NewDBWithOptions(path, Options{})
+	if !errors.Is(err, ErrEncryptionKeyRequired) {
+		t.Errorf("got %v opening without a key, want ErrEncryptionKeyRequired", err)
+	}
+
+	db, err = NewDBWithOptions(path, Options{EncryptionKey: testKey(1)})
+	if err != nil {
+		t.Fatalf("unable to open with the right key: %s", err)
+	}
+	defer db.Close()
+	_, err = db.GetUserByID(1)
+	if err != nil {
+		t.Errorf("user lost: %s", err)
+	}
+}
+
+func TestEncryptedBackupIsCompressed(t *testing.T) {
+	dir := t.TempDir()
+	db, err := NewDBWithOptions(filepath.Join(dir, "database.json"), Options{EncryptionKey: testKey(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	createUsers(t, db, 200)
+
+	backup, err := CreateBackup(db, filepath.Join(dir, "backups"), 0)
+	if err != nil {
+		t.Fatalf("unable to back up: %s", err)
+	}
+
+	db.mux.RLock()
+	data, err := os.ReadFile(db.path)
+	db.mux.RUnlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backup.Size*2 > int64(len(data)) {
+		t.Errorf("backup is %d bytes, the encrypted db is %d", backup.Size, len(data))
+	}
+
+	restored, err := NewDBWithOptions(filepath.Join(dir, "restored.json"), Options{EncryptionKey: testKey(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+	err = RestoreBackup(restored, filepath.Join(dir, "backups"), backup.Name)
+	if err != nil {
+		t.Fatalf("unable to restore: %s", err)
+	}
+	_, err = restored.GetUserByID(200)
+	if err != nil {
+		t.Errorf("user lost in the backup: %s", err)
+	}
+
+	other, err := NewDBWithOptions(filepath.Join(dir, "other.json"), Options{EncryptionKey: testKey(2)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	err = RestoreBackup(other, filepath.Join(dir, "backups"), backup.Name)
+	if !errors.Is(err, ErrWrongKey) {
+		t.Errorf("got %v restoring under another key, want ErrWrongKey", err)
+	}
+}
+
+func TestRotateEncryptionKeyRewritesMigrationBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database.json")
+	err := os.WriteFile(path, []byte(unversionedSnapshot), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := NewDBWithOptions(path, Options{EncryptionKey: testKey(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Close()
+
+	backups, err := migrationBackups(path)
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("got migration backups %v, %v, want one", backups, err)
+	}
+
+	err = RotateEncryptionKey(path, testKey(1), testKey(2))
+	if err != nil {
+		t.Fatalf("unable to rotate: %s", err)
+	}
+
+	data, err := os.ReadFile(backups[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldCipher, _ := newCipherKey(testKey(1))
+	_, err = oldCipher.decode(data)
+	if !errors.Is(err, ErrWrongKey) {
+		t.Errorf("got %v opening the migration backup with the old key, want ErrWrongKey", err)
+	}
+	newCipher, _ := newCipherKey(testKey(2))
+	plaintext, err := newCipher.decode(data)
+	if err != nil {
+		t.Fatalf("unable to open the migration backup with the new key: %s", err)
+	}
+	if !bytes.Contains(plaintext, []byte("old@example.com")) {
+		t.Errorf("migration backup lost its data: %s", plaintext)
+	}
+}
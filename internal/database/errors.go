@@ -0,0 +1,27 @@
+package database
+
+import "errors"
+
+var (
+	ErrUserNotFound         = errors.New("user does not exist")
+	ErrHandleTaken          = errors.New("handle is already taken")
+	ErrInvalidHandle        = errors.New("invalid handle")
+	ErrFollowSelf           = errors.New("users cannot follow themselves")
+	ErrChirpNotFound        = errors.New("chirp not found")
+	ErrChirpNotDeleted      = errors.New("chirp is not deleted")
+	ErrRestoreWindowExpired = errors.New("chirp was deleted too long ago to restore")
+	ErrVersionMismatch      = errors.New("resource has been modified since it was read")
+	ErrParentNotFound       = errors.New("the chirp being replied to does not exist")
+	ErrOriginalNotFound     = errors.New("the chirp being shared does not exist")
+	ErrAlreadyRechirped     = errors.New("chirp has already been rechirped")
+	ErrRechirpNotEditable   = errors.New("rechirps have no body to edit")
+	ErrEventsPurged         = errors.New("events after the cursor have been purged")
+)
+
+// AnyVersion skips the version check on methods that take the version the
+// caller expects to be changing
+const AnyVersion = 0
+
+func versionMatches(expected, actual int) bool {
+	return expected == AnyVersion || expected == actual
+}
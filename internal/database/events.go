@@ -0,0 +1,144 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+)
+
+const (
+	EventChirpCreated   = "chirp.created"
+	EventChirpEdited    = "chirp.edited"
+	EventChirpDeleted   = "chirp.deleted"
+	EventChirpRestored  = "chirp.restored"
+	EventChirpPurged    = "chirp.purged"
+	EventChirpLiked     = "chirp.liked"
+	EventChirpUnliked   = "chirp.unliked"
+	EventChirpFlagged   = "chirp.flagged"
+	EventUserCreated    = "user.created"
+	EventUserUpdated    = "user.updated"
+	EventUserUpgraded   = "user.upgraded"
+	EventUserFollowed   = "user.followed"
+	EventUserUnfollowed = "user.unfollowed"
+	EventTokenRevoked   = "token.revoked"
+)
+
+// Event is one entry of the change feed. Seq is assigned in commit order
+// and never reused, so a consumer can resume from the last Seq it saw.
+type Event struct {
+	Seq  int             `json:"seq"`
+	Type string          `json:"type"`
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data"`
+}
+
+// userEventData is what events carry about a user, never the password
+type userEventData struct {
+	Id          int    `json:"id"`
+	Email       string `json:"email"`
+	Handle      string `json:"handle,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	Bio         string `json:"bio,omitempty"`
+	AvatarURL   string `json:"avatar_url,omitempty"`
+	IsChirpyRed bool   `json:"is_chirpy_red"`
+	Version     int    `json:"version"`
+}
+
+// flagEventData asks moderators to review a chirp
+type flagEventData struct {
+	ChirpID  int      `json:"chirp_id"`
+	AuthorID int      `json:"author_id"`
+	Body     string   `json:"body"`
+	Rules    []string `json:"rules"`
+}
+
+func flagEvent(chirp Chirp, rules []string) flagEventData {
+	return flagEventData{
+		ChirpID:  chirp.Id,
+		AuthorID: chirp.AuthorID,
+		Body:     chirp.Body,
+		Rules:    rules,
+	}
+}
+
+type tokenEventData struct {
+	UserID int `json:"user_id"`
+}
+
+func userEvent(user User) userEventData {
+	return userEventData{
+		Id:          user.Id,
+		Email:       user.Email,
+		Handle:      user.Handle,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarURL:   user.AvatarURL,
+		IsChirpyRed: user.IsChirpyRed,
+		Version:     user.Version,
+	}
+}
+
+// recordEvent appends an event to the feed. Only call it inside Update, so
+// the event commits or rolls back together with the change it describes.
+func (dbStructure *DBStructure) recordEvent(eventType string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("unable to encode %s event: %s", eventType, err)
+	}
+
+	seq := dbStructure.nextID(sequenceEvents)
+	dbStructure.putEvent(Event{
+		Seq:  seq,
+		Type: eventType,
+		Time: time.Now(),
+		Data: payload,
+	})
+
+	return nil
+}
+
+// GetEvents returns up to limit events with a Seq greater than after, in
+// order. It fails with ErrEventsPurged when events right after after have
+// already been purged, an after of 0 starts from the oldest kept event.
+func (db *DB) GetEvents(after, limit int) ([]Event, error) {
+	events := []Event{}
+	err := db.View(func(dbStructure *DBStructure) error {
+		seqs := db.idx.eventSeqs
+		oldest := dbStructure.Sequences[sequenceEvents] + 1
+		if len(seqs) > 0 {
+			oldest = seqs[0]
+		}
+		if after != 0 && after < oldest-1 {
+			return ErrEventsPurged
+		}
+
+		i, _ := slices.BinarySearch(seqs, after+1)
+		for ; i < len(seqs) && len(events) < limit; i++ {
+			events = append(events, dbStructure.Events[seqs[i]])
+		}
+		return nil
+	})
+
+	return events, err
+}
+
+// PurgeEvents removes events recorded before before and returns how many
+// were removed
+func (db *DB) PurgeEvents(before time.Time) (int, error) {
+	purged := 0
+	err := db.Update(func(dbStructure *DBStructure) error {
+		for seq, event := range dbStructure.Events {
+			if event.Time.Before(before) {
+				dbStructure.deleteEvent(seq)
+				purged++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,61 @@
+package database
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGetEventsAfterPurge(t *testing.T) {
+	testStores(t, func(t *testing.T, open func() Store) {
+		store := open()
+		defer store.Close()
+
+		createUsers(t, store, 3)
+		cutoff := time.Now()
+		time.Sleep(time.Millisecond)
+		for _, email := range []string{"late1@example.com", "late2@example.com"} {
+			_, err := store.CreateUsers(User{Email: email, Password: "hash"})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		purged, err := store.PurgeEvents(cutoff)
+		if err != nil || purged != 3 {
+			t.Fatalf("purged %d events, %v, want 3", purged, err)
+		}
+
+		for _, after := range []int{0, 3} {
+			events, err := store.GetEvents(after, 10)
+			if err != nil {
+				t.Fatalf("after %d: %s", after, err)
+			}
+			if len(events) != 2 || events[0].Seq != 4 || events[1].Seq != 5 {
+				t.Errorf("after %d got %+v, want events 4 and 5", after, events)
+			}
+		}
+		events, err := store.GetEvents(4, 1)
+		if err != nil || len(events) != 1 || events[0].Seq != 5 {
+			t.Errorf("after 4 got %+v, %v, want event 5", events, err)
+		}
+
+		_, err = store.GetEvents(2, 10)
+		if !errors.Is(err, ErrEventsPurged) {
+			t.Errorf("after 2 got %v, want ErrEventsPurged", err)
+		}
+
+		_, err = store.PurgeEvents(time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		events, err = store.GetEvents(5, 10)
+		if err != nil || len(events) != 0 {
+			t.Errorf("after the last event got %+v, %v, want nothing", events, err)
+		}
+		_, err = store.GetEvents(4, 10)
+		if !errors.Is(err, ErrEventsPurged) {
+			t.Errorf("after 4 with every event purged got %v, want ErrEventsPurged", err)
+		}
+	})
+}
@@ -0,0 +1,103 @@
+package database
+
+import (
+	"math"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// a hashtag starts a word and runs over letters, digits and underscores
+var hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&/#])#([\p{L}\p{N}_]{1,100})`)
+
+// ExtractHashtags returns the hashtags in body, lowercased, without the #
+// and in order of first appearance. Tags made only of digits and
+// underscores, like #1, are not hashtags.
+func ExtractHashtags(body string) []string {
+	tags := []string{}
+	for _, match := range hashtagPattern.FindAllStringSubmatch(body, -1) {
+		tag := strings.ToLower(match[1])
+		if !strings.ContainsFunc(tag, unicode.IsLetter) {
+			continue
+		}
+		if !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// NormalizeHashtag returns the form tag is stored in, with or without its
+// leading #, and false when it is not a valid hashtag
+func NormalizeHashtag(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+	tags := ExtractHashtags("#" + tag)
+	if len(tags) != 1 || tags[0] != tag {
+		return "", false
+	}
+
+	return tag, true
+}
+
+// TrendingQuery selects the hashtags used since Since. Each use weighs
+// half as much for every HalfLife that has passed between it and Now.
+type TrendingQuery struct {
+	Since    time.Time
+	Now      time.Time
+	HalfLife time.Duration
+	Limit    int
+}
+
+type TrendingHashtag struct {
+	Tag string `json:"tag"`
+	// Count is how many chirps used the tag in the window
+	Count int `json:"count"`
+	// Score is the decayed count the tags are ranked by
+	Score float64 `json:"score"`
+}
+
+// hashtagUse is one chirp using one hashtag
+type hashtagUse struct {
+	tag       string
+	createdAt time.Time
+}
+
+// rankTrending scores uses by q and returns the q.Limit best tags
+func rankTrending(uses []hashtagUse, q TrendingQuery) []TrendingHashtag {
+	byTag := map[string]*TrendingHashtag{}
+	for _, use := range uses {
+		trending, ok := byTag[use.tag]
+		if !ok {
+			trending = &TrendingHashtag{Tag: use.tag}
+			byTag[use.tag] = trending
+		}
+
+		age := max(q.Now.Sub(use.createdAt), 0)
+		trending.Count++
+		trending.Score += math.Exp2(-float64(age) / float64(q.HalfLife))
+	}
+
+	ranked := make([]TrendingHashtag, 0, len(byTag))
+	for _, trending := range byTag {
+		ranked = append(ranked, *trending)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		if ranked[i].Count != ranked[j].Count {
+			return ranked[i].Count > ranked[j].Count
+		}
+		return ranked[i].Tag < ranked[j].Tag
+	})
+
+	if q.Limit > 0 && len(ranked) > q.Limit {
+		ranked = ranked[:q.Limit]
+	}
+
+	return ranked
+}
@@ -0,0 +1,215 @@
+package database
+
+import "slices"
+
+// indexes are lookups derived from DBStructure. They are never persisted:
+// they are built when the db is opened and kept in step by Update from the
+// keys each transaction touched. Refresh tokens need no index of their own
+// to find their user, RefreshTokens is already keyed by token.
+type indexes struct {
+	userByEmail    map[string]int
+	userByHandle   map[string]int
+	chirpsByAuthor map[int]map[int]struct{}
+	repliesByChirp map[int]map[int]struct{}
+	rechirpsOf     map[int]map[int]struct{}
+	quotesOf       map[int]map[int]struct{}
+	chirpsByTag    map[string]map[int]struct{}
+	mentionsOf     map[int]map[int]struct{}
+	likesByChirp   map[int]map[int]struct{}
+	likesByUser    map[int]map[int]struct{}
+	// following maps a user to the users they follow, followers the other
+	// way around
+	following map[int]map[int]struct{}
+	followers map[int]map[int]struct{}
+	// eventSeqs holds the seq of every kept event in order, so polling the
+	// feed does not sort it
+	eventSeqs []int
+	search    *searchIndex
+}
+
+func newIndexes(dbStructure DBStructure) *indexes {
+	idx := &indexes{
+		userByEmail:    map[string]int{},
+		userByHandle:   map[string]int{},
+		chirpsByAuthor: map[int]map[int]struct{}{},
+		repliesByChirp: map[int]map[int]struct{}{},
+		rechirpsOf:     map[int]map[int]struct{}{},
+		quotesOf:       map[int]map[int]struct{}{},
+		chirpsByTag:    map[string]map[int]struct{}{},
+		mentionsOf:     map[int]map[int]struct{}{},
+		likesByChirp:   map[int]map[int]struct{}{},
+		likesByUser:    map[int]map[int]struct{}{},
+		following:      map[int]map[int]struct{}{},
+		followers:      map[int]map[int]struct{}{},
+		search:         newSearchIndex(),
+	}
+
+	for _, user := range dbStructure.Users {
+		idx.addUser(user)
+	}
+	for _, chirp := range dbStructure.Chirps {
+		idx.addChirp(chirp)
+	}
+	for _, like := range dbStructure.Likes {
+		idx.addLike(like)
+	}
+	for _, follow := range dbStructure.Follows {
+		idx.addFollow(follow)
+	}
+	for seq := range dbStructure.Events {
+		idx.eventSeqs = append(idx.eventSeqs, seq)
+	}
+	slices.Sort(idx.eventSeqs)
+
+	return idx
+}
+
+// apply moves the indexes past the rows a transaction changed
+func (idx *indexes) apply(changes []rowChange) {
+	purged := map[int]bool{}
+	for _, change := range changes {
+		current, exists := change.current()
+		switch change.table {
+		case "users":
+			if change.existed {
+				idx.removeUser(change.old.(User))
+			}
+			if exists {
+				idx.addUser(current.(User))
+			}
+		case "chirps":
+			if change.existed {
+				idx.removeChirp(change.old.(Chirp))
+			}
+			if exists {
+				idx.addChirp(current.(Chirp))
+			}
+		case "likes":
+			if change.existed {
+				idx.removeLike(change.old.(Like))
+			}
+			if exists {
+				idx.addLike(current.(Like))
+			}
+		case "follows":
+			if change.existed {
+				idx.removeFollow(change.old.(Follow))
+			}
+			if exists {
+				idx.addFollow(current.(Follow))
+			}
+		case "events":
+			if change.existed && !exists {
+				purged[change.old.(Event).Seq] = true
+			}
+			if !change.existed && exists {
+				idx.addEvent(current.(Event).Seq)
+			}
+		}
+	}
+	// a purge removes many events at once, they go in a single pass
+	if len(purged) > 0 {
+		idx.eventSeqs = slices.DeleteFunc(idx.eventSeqs, func(seq int) bool {
+			return purged[seq]
+		})
+	}
+}
+
+func (idx *indexes) addUser(user User) {
+	idx.userByEmail[user.Email] = user.Id
+	if user.Handle != "" {
+		idx.userByHandle[user.Handle] = user.Id
+	}
+}
+
+func (idx *indexes) removeUser(user User) {
+	if idx.userByEmail[user.Email] == user.Id {
+		delete(idx.userByEmail, user.Email)
+	}
+	if user.Handle != "" && idx.userByHandle[user.Handle] == user.Id {
+		delete(idx.userByHandle, user.Handle)
+	}
+}
+
+func (idx *indexes) addChirp(chirp Chirp) {
+	addToSet(idx.chirpsByAuthor, chirp.AuthorID, chirp.Id)
+	if chirp.InReplyTo != 0 {
+		addToSet(idx.repliesByChirp, chirp.InReplyTo, chirp.Id)
+	}
+	if chirp.RechirpOf != 0 {
+		addToSet(idx.rechirpsOf, chirp.RechirpOf, chirp.Id)
+	}
+	if chirp.QuoteOf != 0 {
+		addToSet(idx.quotesOf, chirp.QuoteOf, chirp.Id)
+	}
+	for _, tag := range chirp.Hashtags {
+		addToSet(idx.chirpsByTag, tag, chirp.Id)
+	}
+	for _, userID := range mentionedUsers(chirp.Mentions) {
+		addToSet(idx.mentionsOf, userID, chirp.Id)
+	}
+	idx.search.add(chirp)
+}
+
+func (idx *indexes) removeChirp(chirp Chirp) {
+	removeFromSet(idx.chirpsByAuthor, chirp.AuthorID, chirp.Id)
+	removeFromSet(idx.repliesByChirp, chirp.InReplyTo, chirp.Id)
+	removeFromSet(idx.rechirpsOf, chirp.RechirpOf, chirp.Id)
+	removeFromSet(idx.quotesOf, chirp.QuoteOf, chirp.Id)
+	for _, tag := range chirp.Hashtags {
+		removeFromSet(idx.chirpsByTag, tag, chirp.Id)
+	}
+	for _, userID := range mentionedUsers(chirp.Mentions) {
+		removeFromSet(idx.mentionsOf, userID, chirp.Id)
+	}
+	idx.search.remove(chirp)
+}
+
+func (idx *indexes) addLike(like Like) {
+	addToSet(idx.likesByChirp, like.ChirpID, like.UserID)
+	addToSet(idx.likesByUser, like.UserID, like.ChirpID)
+}
+
+func (idx *indexes) removeLike(like Like) {
+	removeFromSet(idx.likesByChirp, like.ChirpID, like.UserID)
+	removeFromSet(idx.likesByUser, like.UserID, like.ChirpID)
+}
+
+func (idx *indexes) addFollow(follow Follow) {
+	addToSet(idx.following, follow.FollowerID, follow.FolloweeID)
+	addToSet(idx.followers, follow.FolloweeID, follow.FollowerID)
+}
+
+func (idx *indexes) removeFollow(follow Follow) {
+	removeFromSet(idx.following, follow.FollowerID, follow.FolloweeID)
+	removeFromSet(idx.followers, follow.FolloweeID, follow.FollowerID)
+}
+
+// addEvent keeps eventSeqs in order, new events have the highest seq so
+// this is almost always an append
+func (idx *indexes) addEvent(seq int) {
+	i, found := slices.BinarySearch(idx.eventSeqs, seq)
+	if !found {
+		idx.eventSeqs = slices.Insert(idx.eventSeqs, i, seq)
+	}
+}
+
+func addToSet[K, V comparable](index map[K]map[V]struct{}, key K, val V) {
+	set, ok := index[key]
+	if !ok {
+		set = map[V]struct{}{}
+		index[key] = set
+	}
+	set[val] = struct{}{}
+}
+
+func removeFromSet[K, V comparable](index map[K]map[V]struct{}, key K, val V) {
+	set, ok := index[key]
+	if !ok {
+		return
+	}
+	delete(set, val)
+	if len(set) == 0 {
+		delete(index, key)
+	}
+}
@@ -0,0 +1,114 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Janitor periodically removes data that is no longer needed from a Store:
+// stale refresh tokens, chirps that have been in the trash for longer than
+// the retention window, and events older than theirs
+type Janitor struct {
+	store          Store
+	interval       time.Duration
+	chirpRetention time.Duration
+	eventRetention time.Duration
+
+	mux   sync.Mutex
+	stats JanitorStats
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+type JanitorStats struct {
+	Runs               int       `json:"runs"`
+	RefreshTokensSwept int       `json:"refresh_tokens_swept"`
+	ChirpsPurged       int       `json:"chirps_purged"`
+	EventsPurged       int       `json:"events_purged"`
+	LastRun            time.Time `json:"last_run"`
+	LastError          string    `json:"last_error,omitempty"`
+}
+
+// StartJanitor sweeps store every interval until Stop is called
+func StartJanitor(store Store, interval, chirpRetention, eventRetention time.Duration) (*Janitor, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("janitor interval must be more than 0, got %s", interval)
+	}
+
+	j := &Janitor{
+		store:          store,
+		interval:       interval,
+		chirpRetention: chirpRetention,
+		eventRetention: eventRetention,
+		stop:           make(chan struct{}),
+		done:           make(chan struct{}),
+	}
+	go j.run()
+
+	return j, nil
+}
+
+// Stop waits for a sweep in progress to finish and stops the janitor
+func (j *Janitor) Stop() {
+	j.stopOnce.Do(func() {
+		close(j.stop)
+	})
+	<-j.done
+}
+
+func (j *Janitor) Stats() JanitorStats {
+	j.mux.Lock()
+	defer j.mux.Unlock()
+
+	return j.stats
+}
+
+func (j *Janitor) run() {
+	defer close(j.done)
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-j.stop:
+			return
+		case <-ticker.C:
+			j.sweep()
+		}
+	}
+}
+
+func (j *Janitor) sweep() {
+	now := time.Now()
+	swept, tokenErr := j.store.PurgeStaleRefreshTokens(now)
+	if tokenErr != nil {
+		log.Printf("unable to sweep refresh tokens: %s", tokenErr)
+	}
+	purged, chirpErr := j.store.PurgeDeletedChirps(now.Add(-j.chirpRetention))
+	if chirpErr != nil {
+		log.Printf("unable to purge deleted chirps: %s", chirpErr)
+	}
+	events, eventErr := j.store.PurgeEvents(now.Add(-j.eventRetention))
+	if eventErr != nil {
+		log.Printf("unable to purge old events: %s", eventErr)
+	}
+
+	j.mux.Lock()
+	defer j.mux.Unlock()
+
+	j.stats.Runs++
+	j.stats.LastRun = now
+	j.stats.RefreshTokensSwept += swept
+	j.stats.ChirpsPurged += purged
+	j.stats.EventsPurged += events
+	j.stats.LastError = ""
+	if err := errors.Join(tokenErr, chirpErr, eventErr); err != nil {
+		j.stats.LastError = err.Error()
+	}
+}
@@ -0,0 +1,246 @@
+package database
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// The journal is an append-only file next to the snapshot holding one JSON
+// line per committed write. A write is durable as soon as its line has been
+// fsynced; the snapshot is then rewritten and the journal truncated. Every
+// entry carries the full new value of what it touches, so replaying a
+// journal that has already been folded into the snapshot is harmless.
+
+const (
+	journalOpPut    = "put"
+	journalOpDelete = "delete"
+	journalOpSet    = "set"
+)
+
+type journalEntry struct {
+	Op    string          `json:"op"`
+	Table string          `json:"table"`
+	Key   string          `json:"key,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type journalRecord struct {
+	Checksum uint32          `json:"crc"`
+	Entries  json.RawMessage `json:"entries"`
+}
+
+func (db *DB) journalPath() string {
+	return db.path + ".wal"
+}
+
+// applyJournalEntry replays a single entry against dbStructure
+func applyJournalEntry(dbStructure *DBStructure, entry journalEntry) error {
+	structVal := reflect.ValueOf(dbStructure).Elem()
+
+	var field reflect.Value
+	for i := 0; i < structVal.NumField(); i++ {
+		if journalTable(structVal.Type().Field(i)) == entry.Table {
+			field = structVal.Field(i)
+			break
+		}
+	}
+	if !field.IsValid() {
+		return fmt.Errorf("journal references unknown table: %s", entry.Table)
+	}
+
+	if entry.Op == journalOpSet {
+		return json.Unmarshal(entry.Value, field.Addr().Interface())
+	}
+
+	if field.Kind() != reflect.Map {
+		return fmt.Errorf("journal op %s on non map table: %s", entry.Op, entry.Table)
+	}
+	if field.IsNil() {
+		field.Set(reflect.MakeMap(field.Type()))
+	}
+
+	key := reflect.New(field.Type().Key()).Elem()
+	switch key.Kind() {
+	case reflect.String:
+		key.SetString(entry.Key)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(entry.Key, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid journal key %q for table %s", entry.Key, entry.Table)
+		}
+		key.SetInt(n)
+	default:
+		return fmt.Errorf("unsupported key type for table %s", entry.Table)
+	}
+
+	switch entry.Op {
+	case journalOpPut:
+		value := reflect.New(field.Type().Elem())
+		err := json.Unmarshal(entry.Value, value.Interface())
+		if err != nil {
+			return fmt.Errorf("unable to decode journal value for table %s: %s", entry.Table, err)
+		}
+		field.SetMapIndex(key, value.Elem())
+	case journalOpDelete:
+		field.SetMapIndex(key, reflect.Value{})
+	default:
+		return fmt.Errorf("unknown journal op: %s", entry.Op)
+	}
+
+	return nil
+}
+
+func journalTable(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// appendJournal durably appends one record holding entries
+func (db *DB) appendJournal(entries []journalEntry) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("unable to marshal journal entries: %s", err)
+	}
+
+	line, err := json.Marshal(journalRecord{
+		Checksum: crc32.ChecksumIEEE(data),
+		Entries:  data,
+	})
+	if err != nil {
+		return fmt.Errorf("unable to marshal journal record: %s", err)
+	}
+	if db.key != nil {
+		sealed, err := db.key.seal(line)
+		if err != nil {
+			return fmt.Errorf("unable to encrypt journal record: %s", err)
+		}
+		line = []byte(base64.StdEncoding.EncodeToString(sealed))
+	}
+	line = append(line, '\n')
+
+	f, err := os.OpenFile(db.journalPath(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to open journal: %s", err)
+	}
+	defer f.Close()
+
+	_, err = f.Write(line)
+	if err != nil {
+		return fmt.Errorf("unable to append to journal: %s", err)
+	}
+
+	return f.Sync()
+}
+
+// readJournal returns every complete record in the journal. A torn final
+// line is what a crash mid-append leaves behind and is dropped; a bad line
+// anywhere else means the journal itself is corrupt.
+func (db *DB) readJournal() ([][]journalEntry, error) {
+	data, err := os.ReadFile(db.journalPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read journal: %s", err)
+	}
+
+	lines := bytes.Split(bytes.TrimRight(data, "\n"), []byte("\n"))
+	records := make([][]journalEntry, 0, len(lines))
+	for i, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+
+		entries, err := db.decodeJournalLine(line)
+		if errors.Is(err, ErrWrongKey) || errors.Is(err, ErrEncryptionKeyRequired) {
+			return nil, fmt.Errorf("unable to decrypt journal: %w", err)
+		}
+		if err != nil {
+			if i == len(lines)-1 {
+				break
+			}
+			return nil, fmt.Errorf("journal corrupt at line %d: %s", i+1, err)
+		}
+		records = append(records, entries)
+	}
+
+	return records, nil
+}
+
+// decodeJournalLine parses a journal line, which is base64 when the db is
+// encrypted. Lines journaled before encryption was turned on stay readable.
+func (db *DB) decodeJournalLine(line []byte) ([]journalEntry, error) {
+	sealed, err := base64.StdEncoding.DecodeString(string(line))
+	if err == nil && isSealed(sealed) {
+		line, err = db.key.decode(sealed)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	record := journalRecord{}
+	err = json.Unmarshal(line, &record)
+	if err != nil {
+		return nil, err
+	}
+
+	if crc32.ChecksumIEEE(record.Entries) != record.Checksum {
+		return nil, errors.New("checksum mismatch")
+	}
+
+	entries := []journalEntry{}
+	err = json.Unmarshal(record.Entries, &entries)
+	return entries, err
+}
+
+// writeFileAtomic replaces path with data so that readers, and the file
+// after a crash, only ever see the old or the new contents.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(tmpName, perm)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmpName, path)
+	if err != nil {
+		return err
+	}
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
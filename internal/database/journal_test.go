@@ -0,0 +1,91 @@
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// openJSON opens the JSON db at path, journaling writes without rewriting
+// the snapshot so a test can stop without Close to simulate a crash
+func openJSON(t *testing.T, path string) *DB {
+	t.Helper()
+
+	db, err := NewDBWithOptions(path, Options{Persist: PersistOnShutdown})
+	if err != nil {
+		t.Fatalf("unable to open db: %s", err)
+	}
+	return db
+}
+
+func createUsers(t *testing.T, db Store, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		_, err := db.CreateUsers(User{Email: fmt.Sprintf("user%d@example.com", i), Password: "hash"})
+		if err != nil {
+			t.Fatalf("unable to create user: %s", err)
+		}
+	}
+}
+
+func TestJournalReplaysWritesMissingFromSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database.json")
+
+	createUsers(t, openJSON(t, path), 3)
+
+	db := openJSON(t, path)
+	defer db.Close()
+	for id := 1; id <= 3; id++ {
+		user, err := db.GetUserByID(id)
+		if err != nil {
+			t.Fatalf("user %d was not replayed: %s", id, err)
+		}
+		if user.Email != fmt.Sprintf("user%d@example.com", id-1) {
+			t.Errorf("user %d replayed with email %s", id, user.Email)
+		}
+	}
+}
+
+func TestJournalDropsTornLineBeforeAppending(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database.json")
+	openJSON(t, path).Close()
+
+	// all that is left of a write that crashed mid-append
+	err := os.WriteFile(path+".wal", []byte(`{"crc":1234,"entr`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	createUsers(t, openJSON(t, path), 2)
+
+	db := openJSON(t, path)
+	defer db.Close()
+	for id := 1; id <= 2; id++ {
+		_, err := db.GetUserByID(id)
+		if err != nil {
+			t.Errorf("user %d was lost: %s", id, err)
+		}
+	}
+}
+
+func TestJournalCorruptBeforeLastLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database.json")
+	createUsers(t, openJSON(t, path), 2)
+
+	journal, err := os.ReadFile(path + ".wal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	journal[5] ^= 0xff
+	err = os.WriteFile(path+".wal", journal, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewDBWithOptions(path, Options{Persist: PersistOnShutdown})
+	if err == nil {
+		t.Fatal("expected a corrupt journal to be refused")
+	}
+}
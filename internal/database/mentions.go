@@ -0,0 +1,88 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	handlePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{2,29}$`)
+	// reservedHandles would be confused with routes or staff accounts
+	reservedHandles = []string{"admin", "administrator", "api", "chirpy", "me", "moderator", "root", "support", "system"}
+
+	// a mention starts a word, so the @ of an email address is not one
+	mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@.])(@[A-Za-z0-9_]{1,30})`)
+)
+
+// Mention is a user mentioned in a chirp body. Start and End are the
+// character offsets of the @handle in the body, End exclusive.
+type Mention struct {
+	UserID int    `json:"user_id"`
+	Handle string `json:"handle"`
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+}
+
+// NormalizeHandle returns the form handle is stored in: 3 to 30 letters,
+// digits or underscores starting with a letter, lowercased. It fails with
+// ErrInvalidHandle.
+func NormalizeHandle(handle string) (string, error) {
+	handle = strings.ToLower(handle)
+	if !handlePattern.MatchString(handle) {
+		return "", fmt.Errorf("%w: use 3 to 30 letters, digits or underscores, starting with a letter", ErrInvalidHandle)
+	}
+	if slices.Contains(reservedHandles, handle) {
+		return "", fmt.Errorf("%w: %s is reserved", ErrInvalidHandle, handle)
+	}
+
+	return handle, nil
+}
+
+// resolveMentions finds the @handles in body that userByHandle knows of,
+// others are left as plain text
+func resolveMentions(body string, userByHandle func(handle string) (int, bool)) []Mention {
+	mentions := []Mention{}
+	for _, loc := range mentionPattern.FindAllStringSubmatchIndex(body, -1) {
+		start, end := loc[2], loc[3]
+		// a longer run of handle characters is not a handle at all
+		if next, _ := utf8.DecodeRuneInString(body[end:]); end < len(body) && isHandleRune(next) {
+			continue
+		}
+
+		handle := strings.ToLower(body[start+1 : end])
+		userID, ok := userByHandle(handle)
+		if !ok {
+			continue
+		}
+
+		mentions = append(mentions, Mention{
+			UserID: userID,
+			Handle: handle,
+			Start:  utf8.RuneCountInString(body[:start]),
+			End:    utf8.RuneCountInString(body[:end]),
+		})
+	}
+
+	return mentions
+}
+
+func isHandleRune(r rune) bool {
+	return r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
+
+// mentionedUsers returns the ids of the users mentioned, each once
+func mentionedUsers(mentions []Mention) []int {
+	ids := []int{}
+	seen := map[int]bool{}
+	for _, mention := range mentions {
+		if !seen[mention.UserID] {
+			seen[mention.UserID] = true
+			ids = append(ids, mention.UserID)
+		}
+	}
+
+	return ids
+}
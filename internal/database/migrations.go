@@ -0,0 +1,239 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// migration upgrades a DBStructure from version-1 to version. Migrations
+// run against a copy of the data, so they are free to change it in place.
+type migration struct {
+	version int
+	name    string
+	up      func(*DBStructure) error
+}
+
+// migrations must stay in order and are never edited once released, add a
+// new one instead
+var migrations = []migration{
+	{version: 1, name: "seed id sequences", up: migrateAddSequences},
+	{version: 2, name: "start versioning users and chirps", up: migrateAddVersions},
+	{version: 3, name: "add the event log", up: migrateAddEvents},
+	{version: 4, name: "timestamp chirps", up: migrateAddChirpTimestamps},
+	{version: 5, name: "keep chirp revisions", up: migrateAddRevisions},
+	{version: 6, name: "add likes", up: migrateAddLikes},
+	{version: 7, name: "add follows", up: migrateAddFollows},
+	{version: 8, name: "extract hashtags", up: migrateExtractHashtags},
+}
+
+func latestSchemaVersion() int {
+	return migrations[len(migrations)-1].version
+}
+
+// MigrateDB applies any pending migrations to the JSON database at path and
+// returns the names of the ones applied. With dryRun set they only run
+// against an in-memory copy and nothing on disk is touched.
+func MigrateDB(path string, opts Options, dryRun bool) ([]string, error) {
+	key, err := newCipherKey(opts.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+
+	db := &DB{path: path, key: key}
+	dbStructure, _, err := db.readDB()
+	if err != nil {
+		return nil, err
+	}
+
+	migrated, applied, err := runMigrations(dbStructure)
+	if err != nil || dryRun || len(applied) == 0 {
+		return applied, err
+	}
+
+	db.data = migrated
+	err = db.backupBeforeMigration(dbStructure)
+	if err != nil {
+		return nil, err
+	}
+	return applied, db.checkpoint()
+}
+
+// migrate brings db.data up to the latest schema version, taking a backup
+// of the old data first. Callers must hold the lock.
+func (db *DB) migrate() (bool, error) {
+	migrated, applied, err := runMigrations(db.data)
+	if err != nil {
+		return false, err
+	}
+	if len(applied) == 0 {
+		return false, nil
+	}
+
+	err = db.backupBeforeMigration(db.data)
+	if err != nil {
+		return false, err
+	}
+
+	for _, name := range applied {
+		log.Printf("Applied db migration: %s", name)
+	}
+	db.data = migrated
+	return true, nil
+}
+
+func runMigrations(dbStructure DBStructure) (DBStructure, []string, error) {
+	if dbStructure.SchemaVersion > latestSchemaVersion() {
+		return dbStructure, nil, fmt.Errorf(
+			"db schema version %d is newer than the latest supported version %d",
+			dbStructure.SchemaVersion, latestSchemaVersion(),
+		)
+	}
+
+	migrated := dbStructure.clone()
+	applied := []string{}
+	for _, m := range migrations {
+		if m.version <= migrated.SchemaVersion {
+			continue
+		}
+
+		err := m.up(&migrated)
+		if err != nil {
+			return dbStructure, nil, fmt.Errorf("migration %d (%s) failed: %s", m.version, m.name, err)
+		}
+		migrated.SchemaVersion = m.version
+		applied = append(applied, fmt.Sprintf("%d: %s", m.version, m.name))
+	}
+
+	return migrated, applied, nil
+}
+
+// backupBeforeMigration writes dbStructure next to the db, tagged with its
+// schema version, so a bad migration can be rolled back by hand
+func (db *DB) backupBeforeMigration(dbStructure DBStructure) error {
+	data, err := json.Marshal(dbStructure)
+	if err != nil {
+		return fmt.Errorf("unable to marshal json for migration backup: %s", err)
+	}
+
+	data, err = db.key.encode(data)
+	if err != nil {
+		return fmt.Errorf("unable to encrypt migration backup: %s", err)
+	}
+
+	backupPath := fmt.Sprintf("%s.v%d.%s.bak", db.path, dbStructure.SchemaVersion, time.Now().UTC().Format("20060102T150405Z"))
+	_, err = os.Stat(backupPath)
+	if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("migration backup %s already exists", backupPath)
+	}
+
+	err = writeFileAtomic(backupPath, data, 0600)
+	if err != nil {
+		return fmt.Errorf("unable to write migration backup: %s", err)
+	}
+
+	log.Printf("Backed up db to %s before migrating", backupPath)
+	return nil
+}
+
+// migrationBackups returns the paths of the backups backupBeforeMigration
+// wrote for the db at path
+func migrationBackups(path string) ([]string, error) {
+	return filepath.Glob(path + ".v*.bak")
+}
+
+// migrateAddSequences seeds the sequence counters of a database written
+// before they existed. Chirps were renumbered on delete back then, leaving
+// their Id field out of step with the key they are served under; the key
+// is what GET /api/chirps/{chirpID} has been resolving, so it wins.
+func migrateAddSequences(dbStructure *DBStructure) error {
+	if dbStructure.Sequences == nil {
+		dbStructure.Sequences = map[string]int{}
+	}
+
+	for id, chirp := range dbStructure.Chirps {
+		chirp.Id = id
+		dbStructure.Chirps[id] = chirp
+		dbStructure.Sequences[sequenceChirps] = max(dbStructure.Sequences[sequenceChirps], id)
+	}
+	for id := range dbStructure.Users {
+		dbStructure.Sequences[sequenceUsers] = max(dbStructure.Sequences[sequenceUsers], id)
+	}
+
+	return nil
+}
+
+func migrateAddVersions(dbStructure *DBStructure) error {
+	for id, chirp := range dbStructure.Chirps {
+		chirp.Version = max(chirp.Version, 1)
+		dbStructure.Chirps[id] = chirp
+	}
+	for id, user := range dbStructure.Users {
+		user.Version = max(user.Version, 1)
+		dbStructure.Users[id] = user
+	}
+
+	return nil
+}
+
+func migrateAddEvents(dbStructure *DBStructure) error {
+	if dbStructure.Events == nil {
+		dbStructure.Events = map[int]Event{}
+	}
+
+	return nil
+}
+
+// migrateAddChirpTimestamps stamps chirps written before CreatedAt existed
+// with the time of the migration, their real creation time is lost
+func migrateAddChirpTimestamps(dbStructure *DBStructure) error {
+	now := time.Now()
+	for id, chirp := range dbStructure.Chirps {
+		if chirp.CreatedAt.IsZero() {
+			chirp.CreatedAt = now
+		}
+		if chirp.UpdatedAt.IsZero() {
+			chirp.UpdatedAt = chirp.CreatedAt
+		}
+		dbStructure.Chirps[id] = chirp
+	}
+
+	return nil
+}
+
+func migrateAddRevisions(dbStructure *DBStructure) error {
+	if dbStructure.Revisions == nil {
+		dbStructure.Revisions = map[int][]ChirpRevision{}
+	}
+
+	return nil
+}
+
+func migrateAddLikes(dbStructure *DBStructure) error {
+	if dbStructure.Likes == nil {
+		dbStructure.Likes = map[string]Like{}
+	}
+
+	return nil
+}
+
+func migrateAddFollows(dbStructure *DBStructure) error {
+	if dbStructure.Follows == nil {
+		dbStructure.Follows = map[string]Follow{}
+	}
+
+	return nil
+}
+
+func migrateExtractHashtags(dbStructure *DBStructure) error {
+	for id, chirp := range dbStructure.Chirps {
+		chirp.Hashtags = ExtractHashtags(chirp.Body)
+		dbStructure.Chirps[id] = chirp
+	}
+
+	return nil
+}
@@ -0,0 +1,160 @@
+package database
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// a snapshot from before the schema was versioned, with a chirp stored
+// under a different key than its id
+const unversionedSnapshot = `{
+	"chirps": {"1": {"id": 7, "body": "hello #Go", "author_id": 1}},
+	"users": {"1": {"id": 1, "email": "old@example.com", "password": "hash"}}
+}`
+
+func TestMigrateDBDryRunLeavesFileAlone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database.json")
+	err := os.WriteFile(path, []byte(unversionedSnapshot), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := MigrateDB(path, Options{}, true)
+	if err != nil {
+		t.Fatalf("dry run failed: %s", err)
+	}
+	if len(applied) != len(migrations) {
+		t.Errorf("dry run reported %d migrations, want %d", len(applied), len(migrations))
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, []byte(unversionedSnapshot)) {
+		t.Error("dry run changed the db file")
+	}
+	backups, _ := filepath.Glob(path + ".v*.bak")
+	if len(backups) != 0 {
+		t.Errorf("dry run wrote backups: %v", backups)
+	}
+}
+
+func TestMigrateDBUpgradesOldSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database.json")
+	err := os.WriteFile(path, []byte(unversionedSnapshot), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := MigrateDB(path, Options{}, false)
+	if err != nil {
+		t.Fatalf("migration failed: %s", err)
+	}
+	if len(applied) != len(migrations) {
+		t.Errorf("applied %d migrations, want %d", len(applied), len(migrations))
+	}
+	backups, _ := filepath.Glob(path + ".v0.*.bak")
+	if len(backups) != 1 {
+		t.Errorf("got backups %v, want one of schema version 0", backups)
+	}
+
+	applied, err = MigrateDB(path, Options{}, false)
+	if err != nil || len(applied) != 0 {
+		t.Errorf("migrating again applied %v, %v", applied, err)
+	}
+
+	db := openJSON(t, path)
+	defer db.Close()
+	chirp, err := db.GetChirpByID(1)
+	if err != nil {
+		t.Fatalf("chirp lost in migration: %s", err)
+	}
+	if chirp.Id != 1 || chirp.Version != 1 || chirp.CreatedAt.IsZero() || !slices.Equal(chirp.Hashtags, []string{"go"}) {
+		t.Errorf("chirp not migrated: %+v", chirp)
+	}
+
+	user, err := db.CreateUsers(User{Email: "new@example.com", Password: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Id != 2 {
+		t.Errorf("got user id %d, the users sequence was not seeded", user.Id)
+	}
+}
+
+func TestMigrateSQLiteDBFromFirstVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "database.db")
+	conn, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = conn.Exec(sqliteMigrations[0].sql + `
+		PRAGMA user_version = 1;
+		INSERT INTO users (email, password) VALUES ('old@example.com', 'hash');
+		INSERT INTO chirps (body, author_id) VALUES ('hello #Go', 1);`)
+	conn.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := MigrateSQLiteDB(path, true)
+	if err != nil {
+		t.Fatalf("dry run failed: %s", err)
+	}
+	if len(applied) != len(sqliteMigrations)-1 {
+		t.Errorf("dry run reported %d migrations, want %d", len(applied), len(sqliteMigrations)-1)
+	}
+	if version := sqliteUserVersion(t, path); version != 1 {
+		t.Errorf("dry run moved the schema to version %d", version)
+	}
+
+	applied, err = MigrateSQLiteDB(path, false)
+	if err != nil {
+		t.Fatalf("migration failed: %s", err)
+	}
+	if len(applied) != len(sqliteMigrations)-1 {
+		t.Errorf("applied %d migrations, want %d", len(applied), len(sqliteMigrations)-1)
+	}
+	if version := sqliteUserVersion(t, path); version != len(sqliteMigrations) {
+		t.Errorf("got schema version %d, want %d", version, len(sqliteMigrations))
+	}
+	backups, _ := filepath.Glob(path + ".v1.*.bak")
+	if len(backups) != 1 {
+		t.Errorf("got backups %v, want one of schema version 1", backups)
+	}
+
+	db, err := NewSQLiteDB(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	chirp, err := db.GetChirpByID(1)
+	if err != nil {
+		t.Fatalf("chirp lost in migration: %s", err)
+	}
+	if chirp.Version != 1 || !slices.Equal(chirp.Hashtags, []string{"go"}) {
+		t.Errorf("chirp not migrated: %+v", chirp)
+	}
+}
+
+func sqliteUserVersion(t *testing.T, path string) int {
+	t.Helper()
+
+	conn, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	var version int
+	err = conn.QueryRow(`PRAGMA user_version`).Scan(&version)
+	if err != nil {
+		t.Fatalf("unable to read schema version: %s", err)
+	}
+	return version
+}
@@ -0,0 +1,44 @@
+package database
+
+import (
+	"fmt"
+	"time"
+)
+
+// PersistPolicy controls when the JSON snapshot is rewritten. Every write
+// is journaled before it returns regardless of policy, so none of them can
+// lose committed data; they trade snapshot rewrites against journal size.
+type PersistPolicy int
+
+const (
+	// PersistSync rewrites the snapshot on every write
+	PersistSync PersistPolicy = iota
+	// PersistBatched rewrites the snapshot every PersistInterval if anything changed
+	PersistBatched
+	// PersistOnShutdown only rewrites the snapshot when the db is closed
+	PersistOnShutdown
+)
+
+const defaultPersistInterval = 500 * time.Millisecond
+
+type Options struct {
+	Persist         PersistPolicy
+	PersistInterval time.Duration
+	// EncryptionKey, when set, encrypts everything written to disk with
+	// AES-256-GCM. It must be 32 bytes, see ParseEncryptionKey.
+	EncryptionKey []byte
+}
+
+// ParsePersistPolicy maps "sync", "batched" or "shutdown" to a PersistPolicy
+func ParsePersistPolicy(s string) (PersistPolicy, error) {
+	switch s {
+	case "", "sync":
+		return PersistSync, nil
+	case "batched":
+		return PersistBatched, nil
+	case "shutdown":
+		return PersistOnShutdown, nil
+	default:
+		return PersistSync, fmt.Errorf("unknown persist policy: %s", s)
+	}
+}
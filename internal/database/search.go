@@ -0,0 +1,81 @@
+package database
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+const maxSearchClauses = 16
+
+var ErrEmptySearch = errors.New("search query has no terms")
+
+// SearchQuery is a parsed full-text query. A chirp matches when it matches
+// every clause.
+type SearchQuery struct {
+	clauses []searchClause
+}
+
+// searchClause is a single term, a term prefix (foo*) or a phrase ("foo bar")
+type searchClause struct {
+	terms  []string
+	prefix bool
+}
+
+// ParseSearchQuery parses space separated terms, "quoted phrases" and
+// prefix* terms, all of which must match
+func ParseSearchQuery(s string) (SearchQuery, error) {
+	q := SearchQuery{}
+
+	for i, part := range strings.Split(s, `"`) {
+		if i%2 == 1 {
+			// inside quotes
+			terms := tokenize(part)
+			if len(terms) > 0 {
+				q.clauses = append(q.clauses, searchClause{terms: terms})
+			}
+			continue
+		}
+
+		for _, word := range strings.Fields(part) {
+			terms := tokenize(word)
+			if len(terms) == 0 {
+				continue
+			}
+			for _, term := range terms[:len(terms)-1] {
+				q.clauses = append(q.clauses, searchClause{terms: []string{term}})
+			}
+			q.clauses = append(q.clauses, searchClause{
+				terms:  terms[len(terms)-1:],
+				prefix: strings.HasSuffix(word, "*"),
+			})
+		}
+	}
+
+	if len(q.clauses) == 0 {
+		return SearchQuery{}, ErrEmptySearch
+	}
+	if len(q.clauses) > maxSearchClauses {
+		return SearchQuery{}, errors.New("search query has too many terms")
+	}
+
+	return q, nil
+}
+
+// tokenize splits text into lower case runs of letters and digits with
+// their diacritics removed, so "Café" is the term cafe. This is what the
+// sqlite store's unicode61 tokenizer does by default.
+func tokenize(text string) []string {
+	folded := strings.Builder{}
+	for _, r := range norm.NFD.String(text) {
+		if !unicode.Is(unicode.Mn, r) {
+			folded.WriteRune(unicode.ToLower(r))
+		}
+	}
+
+	return strings.FieldsFunc(folded.String(), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+}
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// BM25 parameters, the same defaults sqlite's bm25() uses so both stores
+// rank alike
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// searchIndex is an inverted index over the bodies of chirps that are not
+// deleted, kept in step by indexes.apply
+type searchIndex struct {
+	// postings maps a term to the positions it appears at in each chirp
+	postings map[string]map[int][]int
+	docLen   map[int]int
+	totalLen int
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		postings: map[string]map[int][]int{},
+		docLen:   map[int]int{},
+	}
+}
+
+func (s *searchIndex) add(chirp Chirp) {
+	if chirp.DeletedAt != nil {
+		return
+	}
+
+	terms := tokenize(chirp.Body)
+	for pos, term := range terms {
+		docs, ok := s.postings[term]
+		if !ok {
+			docs = map[int][]int{}
+			s.postings[term] = docs
+		}
+		docs[chirp.Id] = append(docs[chirp.Id], pos)
+	}
+	s.docLen[chirp.Id] = len(terms)
+	s.totalLen += len(terms)
+}
+
+func (s *searchIndex) remove(chirp Chirp) {
+	n, ok := s.docLen[chirp.Id]
+	if !ok {
+		return
+	}
+
+	for _, term := range tokenize(chirp.Body) {
+		delete(s.postings[term], chirp.Id)
+		if len(s.postings[term]) == 0 {
+			delete(s.postings, term)
+		}
+	}
+	delete(s.docLen, chirp.Id)
+	s.totalLen -= n
+}
+
+// search returns the ids of the chirps matching q, best match first
+func (s *searchIndex) search(q SearchQuery) []int {
+	if len(s.docLen) == 0 {
+		return []int{}
+	}
+
+	scores := map[int]float64{}
+	for i, clause := range q.clauses {
+		freqs := s.clauseFreqs(clause)
+		if i > 0 {
+			for id := range scores {
+				if _, ok := freqs[id]; !ok {
+					delete(scores, id)
+				}
+			}
+		}
+
+		n, df := float64(len(s.docLen)), float64(len(freqs))
+		idf := math.Log((n - df + 0.5) / (df + 0.5))
+		if idf <= 0 {
+			idf = 1e-6
+		}
+		avgLen := float64(s.totalLen) / n
+		for id, tf := range freqs {
+			if _, ok := scores[id]; !ok && i > 0 {
+				continue
+			}
+			norm := 1 - bm25B + bm25B*float64(s.docLen[id])/avgLen
+			scores[id] += idf * float64(tf) * (bm25K1 + 1) / (float64(tf) + bm25K1*norm)
+		}
+	}
+
+	ids := make([]int, 0, len(scores))
+	for id := range scores {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		if scores[ids[i]] != scores[ids[j]] {
+			return scores[ids[i]] > scores[ids[j]]
+		}
+		return ids[i] > ids[j]
+	})
+
+	return ids
+}
+
+// clauseFreqs returns how often clause occurs in each chirp it occurs in
+func (s *searchIndex) clauseFreqs(clause searchClause) map[int]int {
+	freqs := map[int]int{}
+
+	if clause.prefix {
+		prefix := clause.terms[0]
+		for term, docs := range s.postings {
+			if strings.HasPrefix(term, prefix) {
+				for id, positions := range docs {
+					freqs[id] += len(positions)
+				}
+			}
+		}
+		return freqs
+	}
+
+	for id, positions := range s.postings[clause.terms[0]] {
+		for _, pos := range positions {
+			if s.phraseAt(id, pos, clause.terms[1:]) {
+				freqs[id]++
+			}
+		}
+	}
+	return freqs
+}
+
+// phraseAt reports whether rest follows the term at pos in chirp id
+func (s *searchIndex) phraseAt(id, pos int, rest []string) bool {
+	for i, term := range rest {
+		positions := s.postings[term][id]
+		j := sort.SearchInts(positions, pos+i+1)
+		if j == len(positions) || positions[j] != pos+i+1 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,113 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	for query, want := range map[string]string{
+		"coffee":               `"coffee"`,
+		"Coffee  MORNING":      `"coffee" "morning"`,
+		`"morning coffee"`:     `"morning coffee"`,
+		`"Morning, coffee!" x`: `"morning coffee" "x"`,
+		"goph*":                `"goph"*`,
+		"well-known*":          `"well" "known"*`,
+		"Café":                 `"cafe"`,
+		`unclosed "quote`:      `"unclosed" "quote"`,
+	} {
+		q, err := ParseSearchQuery(query)
+		if err != nil {
+			t.Errorf("%q: %s", query, err)
+			continue
+		}
+		if got := q.fts5(); got != want {
+			t.Errorf("%q parsed as %s, want %s", query, got, want)
+		}
+	}
+
+	for _, query := range []string{"", "   ", `""`, "*", "-- !"} {
+		_, err := ParseSearchQuery(query)
+		if !errors.Is(err, ErrEmptySearch) {
+			t.Errorf("%q: got %v, want ErrEmptySearch", query, err)
+		}
+	}
+
+	_, err := ParseSearchQuery(strings.Repeat("term ", maxSearchClauses+1))
+	if err == nil {
+		t.Error("query with too many terms was accepted")
+	}
+}
+
+func TestSearchChirpsAgreesAcrossStores(t *testing.T) {
+	bodies := []string{
+		"Café au lait in the morning",
+		"the morning coffee, coffee and more coffee",
+		"coffee shop opens in the morning",
+		"go gophers love coffee",
+		"gopher party tonight",
+		"a deleted coffee chirp",
+		"naïve résumé",
+	}
+	const deleted = 6
+
+	testStores(t, func(t *testing.T, open func() Store) {
+		store := open()
+		defer store.Close()
+
+		createUsers(t, store, 1)
+		for _, body := range bodies {
+			_, err := store.CreateChirp(Chirp{Body: body, AuthorID: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		err := store.DeleteChirpByID(deleted, AnyVersion)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		search := func(query string, offset, limit int) string {
+			q, err := ParseSearchQuery(query)
+			if err != nil {
+				t.Fatalf("%q: %s", query, err)
+			}
+			chirps, err := store.SearchChirps(q, offset, limit)
+			if err != nil {
+				t.Fatalf("%q: %s", query, err)
+			}
+			ids := []int{}
+			for _, chirp := range chirps {
+				ids = append(ids, chirp.Id)
+			}
+			return fmt.Sprint(ids)
+		}
+
+		for query, want := range map[string]string{
+			"cafe":             "[1]",
+			"CAFÉ":             "[1]",
+			"cafe\u0301":       "[1]",
+			"resume naive":     "[7]",
+			"coffee":           "[2 4 3]",
+			"coffee morning":   "[2 3]",
+			`"morning coffee"`: "[2]",
+			`"coffee morning"`: "[]",
+			"goph*":            "[5 4]",
+			"coffee tea":       "[]",
+			"deleted":          "[]",
+		} {
+			if got := search(query, 0, 10); got != want {
+				t.Errorf("%q found %s, want %s", query, got, want)
+			}
+		}
+
+		if got := search("coffee", 1, 1); got != "[4]" {
+			t.Errorf("second page of coffee is %s, want [4]", got)
+		}
+		if got := search("coffee", 3, 10); got != "[]" {
+			t.Errorf("page past the end of coffee is %s, want []", got)
+		}
+	})
+}
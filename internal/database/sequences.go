@@ -0,0 +1,14 @@
+package database
+
+const (
+	sequenceChirps = "chirps"
+	sequenceUsers  = "users"
+	sequenceEvents = "events"
+)
+
+// nextID allocates the next ID for table. Only call it inside Update.
+func (dbStructure *DBStructure) nextID(table string) int {
+	id := dbStructure.Sequences[table] + 1
+	dbStructure.putSequence(table, id)
+	return id
+}
@@ -0,0 +1,133 @@
+package database
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Backup writes a consistent copy of the whole database to w
+func (db *SQLiteDB) Backup(w io.Writer) error {
+	dir, err := os.MkdirTemp(filepath.Dir(db.path), "backup-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	snapshotPath := filepath.Join(dir, "snapshot.db")
+	_, err = db.conn.Exec(`VACUUM INTO ?`, snapshotPath)
+	if err != nil {
+		return fmt.Errorf("unable to snapshot db: %s", err)
+	}
+
+	f, err := os.Open(snapshotPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// Restore replaces the contents of every table with a copy written by
+// Backup. The copy is migrated and integrity checked on its own first, then
+// swapped in with a single transaction.
+func (db *SQLiteDB) Restore(r io.Reader) error {
+	dir, err := os.MkdirTemp(filepath.Dir(db.path), "restore-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+
+	restorePath := filepath.Join(dir, "restore.db")
+	f, err := os.Create(restorePath)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("unable to write backup to disk: %s", err)
+	}
+
+	err = validateSQLiteBackup(restorePath)
+	if err != nil {
+		return fmt.Errorf("invalid backup: %s", err)
+	}
+
+	_, err = db.conn.Exec(`ATTACH DATABASE ? AS restore`, restorePath)
+	if err != nil {
+		return fmt.Errorf("unable to attach backup: %s", err)
+	}
+	defer db.conn.Exec(`DETACH DATABASE restore`)
+
+	tables, err := db.tableNames()
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range append(tables, "sqlite_sequence") {
+		_, err = tx.Exec(fmt.Sprintf(`DELETE FROM main.%q`, table))
+		if err != nil {
+			return fmt.Errorf("unable to clear %s: %s", table, err)
+		}
+		_, err = tx.Exec(fmt.Sprintf(`INSERT INTO main.%q SELECT * FROM restore.%q`, table, table))
+		if err != nil {
+			return fmt.Errorf("unable to restore %s: %s", table, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// validateSQLiteBackup brings the backup at path up to the current schema
+// and checks that sqlite considers it intact
+func validateSQLiteBackup(path string) error {
+	backup, err := NewSQLiteDB(path)
+	if err != nil {
+		return err
+	}
+	defer backup.Close()
+
+	var result string
+	err = backup.conn.QueryRow(`PRAGMA integrity_check`).Scan(&result)
+	if err != nil {
+		return err
+	}
+	if result != "ok" {
+		return fmt.Errorf("integrity check failed: %s", result)
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) tableNames() ([]string, error) {
+	// virtual tables and their shadow tables are kept in step by triggers
+	rows, err := db.conn.Query(`SELECT name FROM pragma_table_list WHERE schema = 'main' AND type = 'table' AND name NOT LIKE 'sqlite_%'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := []string{}
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			return nil, err
+		}
+		tables = append(tables, name)
+	}
+
+	return tables, rows.Err()
+}
@@ -0,0 +1,558 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const sqliteChirpColumns = `id, body, author_id, in_reply_to, rechirp_of, quote_of, hashtags, mentions,
+	reply_count, rechirp_count, quote_count, like_count, created_at, updated_at, edited_at, deleted_at, version`
+
+func (db *SQLiteDB) CreateChirp(chirp Chirp) (Chirp, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return Chirp{}, err
+	}
+	defer tx.Rollback()
+
+	if chirp.InReplyTo != 0 {
+		var exists bool
+		err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM chirps WHERE id = ? AND deleted_at IS NULL)`, chirp.InReplyTo).Scan(&exists)
+		if err != nil {
+			return Chirp{}, err
+		}
+		if !exists {
+			return Chirp{}, fmt.Errorf("unable to insert chirp: %w", ErrParentNotFound)
+		}
+	}
+	if chirp.QuoteOf != 0 {
+		chirp.QuoteOf, err = sqliteOriginalOf(tx, chirp.QuoteOf)
+		if errors.Is(err, sql.ErrNoRows) {
+			return Chirp{}, fmt.Errorf("unable to insert chirp: %w", ErrOriginalNotFound)
+		}
+		if err != nil {
+			return Chirp{}, err
+		}
+	}
+
+	mentions, err := sqliteMentionsIn(tx, chirp.Body)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	chirp, err = insertSQLiteChirp(tx, Chirp{
+		Body:      chirp.Body,
+		AuthorID:  chirp.AuthorID,
+		InReplyTo: chirp.InReplyTo,
+		QuoteOf:   chirp.QuoteOf,
+		Mentions:  mentions,
+	})
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	return chirp, tx.Commit()
+}
+
+// insertSQLiteChirp gives chirp an id and timestamps and stores it
+func insertSQLiteChirp(tx *sql.Tx, chirp Chirp) (Chirp, error) {
+	now := time.Now()
+	chirp.Hashtags = ExtractHashtags(chirp.Body)
+	mentions, err := encodeMentions(chirp.Mentions)
+	if err != nil {
+		return Chirp{}, err
+	}
+	res, err := tx.Exec(
+		`INSERT INTO chirps (body, author_id, in_reply_to, rechirp_of, quote_of, hashtags, mentions, created_at, updated_at)
+		VALUES (?, ?, NULLIF(?, 0), NULLIF(?, 0), NULLIF(?, 0), ?, ?, ?, ?)`,
+		chirp.Body, chirp.AuthorID, chirp.InReplyTo, chirp.RechirpOf, chirp.QuoteOf,
+		strings.Join(chirp.Hashtags, " "), mentions, now.UnixNano(), now.UnixNano(),
+	)
+	if err != nil {
+		return Chirp{}, fmt.Errorf("unable to insert chirp: %s", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	chirp.Id = int(id)
+	chirp.CreatedAt = now
+	chirp.UpdatedAt = now
+	chirp.Version = 1
+
+	err = setSQLiteHashtags(tx, chirp.Id, chirp.Hashtags)
+	if err != nil {
+		return Chirp{}, err
+	}
+	err = setSQLiteMentions(tx, chirp.Id, chirp.Mentions)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	err = countSQLiteReferences(tx, chirp, 1)
+	if err != nil {
+		return Chirp{}, err
+	}
+	err = recordSQLiteEvent(tx, EventChirpCreated, chirp)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	return chirp, nil
+}
+
+func (db *SQLiteDB) GetChirpByID(id int) (Chirp, error) {
+	chirp, err := db.queryChirp(`SELECT `+sqliteChirpColumns+` FROM chirps WHERE id = ? AND deleted_at IS NULL`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Chirp{}, fmt.Errorf("%w: no chirp matching the id %d", ErrChirpNotFound, id)
+	}
+
+	return chirp, err
+}
+
+func (db *SQLiteDB) ListChirps(q ChirpQuery) ([]Chirp, error) {
+	where := []string{`deleted_at IS NULL`}
+	args := []any{}
+
+	if len(q.AuthorIDs) > 0 {
+		where = append(where, `author_id IN (`+placeholders(len(q.AuthorIDs))+`)`)
+		for _, id := range q.AuthorIDs {
+			args = append(args, id)
+		}
+	}
+	if !q.CreatedAfter.IsZero() {
+		where = append(where, `created_at > ?`)
+		args = append(args, q.CreatedAfter.UnixNano())
+	}
+	if !q.CreatedBefore.IsZero() {
+		where = append(where, `created_at < ?`)
+		args = append(args, q.CreatedBefore.UnixNano())
+	}
+	if q.BodyContains != "" {
+		where = append(where, `instr(lower(body), lower(?)) > 0`)
+		args = append(args, q.BodyContains)
+	}
+	if q.ChirpyRedOnly {
+		where = append(where, `author_id IN (SELECT id FROM users WHERE is_chirpy_red = 1)`)
+	}
+	if q.InReplyTo != 0 {
+		where = append(where, `in_reply_to = ?`)
+		args = append(args, q.InReplyTo)
+	}
+	if q.QuoteOf != 0 {
+		where = append(where, `quote_of = ?`)
+		args = append(args, q.QuoteOf)
+	}
+	if q.Mentioning != 0 {
+		where = append(where, `id IN (SELECT chirp_id FROM chirp_mentions WHERE user_id = ?)`)
+		args = append(args, q.Mentioning)
+	}
+	if q.Hashtag != "" {
+		where = append(where, `id IN (SELECT chirp_id FROM chirp_hashtags WHERE tag = ?)`)
+		args = append(args, q.Hashtag)
+	}
+	if q.FollowedBy != 0 {
+		where = append(where, `author_id IN (SELECT followee_id FROM follows WHERE follower_id = ?)`)
+		args = append(args, q.FollowedBy)
+	}
+
+	cmp, order := `>`, `ASC`
+	if q.Descending {
+		cmp, order = `<`, `DESC`
+	}
+	orderBy := `id ` + order
+	if q.SortBy == SortByCreatedAt {
+		orderBy = `created_at ` + order + `, id ` + order
+	}
+
+	if q.After.ID != 0 {
+		if q.SortBy == SortByCreatedAt {
+			where = append(where, `(created_at, id) `+cmp+` (?, ?)`)
+			args = append(args, q.After.CreatedAt.UnixNano(), q.After.ID)
+		} else {
+			where = append(where, `id `+cmp+` ?`)
+			args = append(args, q.After.ID)
+		}
+	}
+
+	query := `SELECT ` + sqliteChirpColumns + ` FROM chirps WHERE ` + strings.Join(where, ` AND `) + ` ORDER BY ` + orderBy
+	if q.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, q.Limit)
+	}
+
+	return db.queryChirps(query, args...)
+}
+
+// placeholders returns n comma separated bind parameters
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat(`?, `, n), `, `)
+}
+
+func (db *SQLiteDB) DeleteChirpByID(id, version int) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	chirp, err := scanChirp(tx.QueryRow(`SELECT `+sqliteChirpColumns+` FROM chirps WHERE id = ? AND deleted_at IS NULL`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: unable to find chirp with provided id", ErrChirpNotFound)
+	}
+	if err != nil {
+		return err
+	}
+	if !versionMatches(version, chirp.Version) {
+		return ErrVersionMismatch
+	}
+
+	now := time.Now()
+	rechirps, err := queryChirps(tx, `SELECT `+sqliteChirpColumns+` FROM chirps WHERE rechirp_of = ? AND deleted_at IS NULL`, id)
+	if err != nil {
+		return err
+	}
+	for _, rechirp := range rechirps {
+		err = trashSQLiteChirp(tx, rechirp.Id, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = trashSQLiteChirp(tx, id, now)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// trashSQLiteChirp sets the deleted_at of a chirp
+func trashSQLiteChirp(tx *sql.Tx, id int, now time.Time) error {
+	chirp, err := scanChirp(tx.QueryRow(
+		`UPDATE chirps SET deleted_at = ?, updated_at = ?, version = version + 1 WHERE id = ? RETURNING `+sqliteChirpColumns,
+		now.UnixNano(), now.UnixNano(), id,
+	))
+	if err != nil {
+		return err
+	}
+
+	err = countSQLiteReferences(tx, chirp, -1)
+	if err != nil {
+		return err
+	}
+
+	return recordSQLiteEvent(tx, EventChirpDeleted, chirp)
+}
+
+func (db *SQLiteDB) GetDeletedChirpByID(id int) (Chirp, error) {
+	chirp, err := db.queryChirp(`SELECT `+sqliteChirpColumns+` FROM chirps WHERE id = ?`, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Chirp{}, fmt.Errorf("%w: no chirp matching the id %d", ErrChirpNotFound, id)
+	}
+	if err != nil {
+		return Chirp{}, err
+	}
+	if chirp.DeletedAt == nil {
+		return Chirp{}, ErrChirpNotDeleted
+	}
+
+	return chirp, nil
+}
+
+func (db *SQLiteDB) RestoreChirpByID(id int, deletedSince time.Time) (Chirp, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return Chirp{}, err
+	}
+	defer tx.Rollback()
+
+	chirp, err := scanChirp(tx.QueryRow(`SELECT `+sqliteChirpColumns+` FROM chirps WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Chirp{}, fmt.Errorf("%w: no chirp matching the id %d", ErrChirpNotFound, id)
+	}
+	if err != nil {
+		return Chirp{}, err
+	}
+	if chirp.DeletedAt == nil {
+		return Chirp{}, ErrChirpNotDeleted
+	}
+	if chirp.DeletedAt.Before(deletedSince) {
+		return Chirp{}, ErrRestoreWindowExpired
+	}
+	if chirp.RechirpOf != 0 {
+		var originalLive, rechirped bool
+		err = tx.QueryRow(
+			`SELECT EXISTS (SELECT 1 FROM chirps WHERE id = ? AND deleted_at IS NULL),
+			EXISTS (SELECT 1 FROM chirps WHERE rechirp_of = ? AND author_id = ? AND deleted_at IS NULL)`,
+			chirp.RechirpOf, chirp.RechirpOf, chirp.AuthorID,
+		).Scan(&originalLive, &rechirped)
+		if err != nil {
+			return Chirp{}, err
+		}
+		if !originalLive {
+			return Chirp{}, ErrOriginalNotFound
+		}
+		if rechirped {
+			return Chirp{}, ErrAlreadyRechirped
+		}
+	}
+
+	now := time.Now()
+	rechirps, err := queryChirps(tx, `SELECT `+sqliteChirpColumns+` FROM chirps WHERE rechirp_of = ? AND deleted_at = ?`, id, chirp.DeletedAt.UnixNano())
+	if err != nil {
+		return Chirp{}, err
+	}
+	for _, rechirp := range rechirps {
+		_, err = untrashSQLiteChirp(tx, rechirp.Id, now)
+		if err != nil {
+			return Chirp{}, err
+		}
+	}
+
+	chirp, err = untrashSQLiteChirp(tx, id, now)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	return chirp, tx.Commit()
+}
+
+// untrashSQLiteChirp clears the deleted_at of a chirp
+func untrashSQLiteChirp(tx *sql.Tx, id int, now time.Time) (Chirp, error) {
+	chirp, err := scanChirp(tx.QueryRow(
+		`UPDATE chirps SET deleted_at = NULL, updated_at = ?, version = version + 1 WHERE id = ? RETURNING `+sqliteChirpColumns,
+		now.UnixNano(), id,
+	))
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	err = countSQLiteReferences(tx, chirp, 1)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	return chirp, recordSQLiteEvent(tx, EventChirpRestored, chirp)
+}
+
+func (db *SQLiteDB) PurgeDeletedChirps(deletedBefore time.Time) (int, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	chirps, err := queryChirps(tx, `SELECT `+sqliteChirpColumns+` FROM chirps WHERE deleted_at < ?`, deletedBefore.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+
+	for _, chirp := range chirps {
+		_, err = tx.Exec(`DELETE FROM chirps WHERE id = ?`, chirp.Id)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.Exec(`DELETE FROM chirp_revisions WHERE chirp_id = ?`, chirp.Id)
+		if err != nil {
+			return 0, err
+		}
+		_, err = tx.Exec(`DELETE FROM likes WHERE chirp_id = ?`, chirp.Id)
+		if err != nil {
+			return 0, err
+		}
+		err = setSQLiteHashtags(tx, chirp.Id, nil)
+		if err != nil {
+			return 0, err
+		}
+		err = setSQLiteMentions(tx, chirp.Id, nil)
+		if err != nil {
+			return 0, err
+		}
+		err = recordSQLiteEvent(tx, EventChirpPurged, chirp)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return len(chirps), tx.Commit()
+}
+
+// setSQLiteHashtags replaces the rows of chirp_hashtags for a chirp, the
+// hashtags column of chirps is the caller's to keep in step
+func setSQLiteHashtags(tx sqlExecer, chirpID int, tags []string) error {
+	_, err := tx.Exec(`DELETE FROM chirp_hashtags WHERE chirp_id = ?`, chirpID)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		_, err = tx.Exec(`INSERT INTO chirp_hashtags (tag, chirp_id) VALUES (?, ?)`, tag, chirpID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) TrendingHashtags(q TrendingQuery) ([]TrendingHashtag, error) {
+	rows, err := db.conn.Query(
+		`SELECT chirp_hashtags.tag, chirps.created_at FROM chirps
+		JOIN chirp_hashtags ON chirp_hashtags.chirp_id = chirps.id
+		WHERE chirps.created_at > ? AND chirps.deleted_at IS NULL`,
+		q.Since.UnixNano(),
+	)
+	if err != nil {
+		return []TrendingHashtag{}, err
+	}
+	defer rows.Close()
+
+	uses := []hashtagUse{}
+	for rows.Next() {
+		use := hashtagUse{}
+		var createdAt int64
+		err = rows.Scan(&use.tag, &createdAt)
+		if err != nil {
+			return []TrendingHashtag{}, err
+		}
+		use.createdAt = time.Unix(0, createdAt)
+		uses = append(uses, use)
+	}
+	if err = rows.Err(); err != nil {
+		return []TrendingHashtag{}, err
+	}
+
+	return rankTrending(uses, q), nil
+}
+
+// countSQLiteReferences adds delta to the reply, rechirp and quote counts
+// of the chirps chirp refers to
+func countSQLiteReferences(tx sqlExecer, chirp Chirp, delta int) error {
+	references := []struct {
+		column string
+		id     int
+	}{
+		{`reply_count`, chirp.InReplyTo},
+		{`rechirp_count`, chirp.RechirpOf},
+		{`quote_count`, chirp.QuoteOf},
+	}
+
+	for _, ref := range references {
+		if ref.id == 0 {
+			continue
+		}
+		_, err := tx.Exec(`UPDATE chirps SET `+ref.column+` = `+ref.column+` + ?, version = version + 1 WHERE id = ?`, delta, ref.id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// sqlQueryer is satisfied by both *sql.DB and *sql.Tx
+type sqlQueryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// scanChirp reads a row selected with sqliteChirpColumns
+func scanChirp(row rowScanner) (Chirp, error) {
+	chirp := Chirp{}
+	var createdAt, updatedAt int64
+	var inReplyTo, rechirpOf, quoteOf, editedAt, deletedAt sql.NullInt64
+	var hashtags, mentions string
+	err := row.Scan(
+		&chirp.Id, &chirp.Body, &chirp.AuthorID, &inReplyTo, &rechirpOf, &quoteOf, &hashtags, &mentions,
+		&chirp.ReplyCount, &chirp.RechirpCount, &chirp.QuoteCount, &chirp.LikeCount,
+		&createdAt, &updatedAt, &editedAt, &deletedAt, &chirp.Version,
+	)
+	if err != nil {
+		return Chirp{}, err
+	}
+	chirp.InReplyTo = int(inReplyTo.Int64)
+	chirp.RechirpOf = int(rechirpOf.Int64)
+	chirp.QuoteOf = int(quoteOf.Int64)
+	if hashtags != "" {
+		chirp.Hashtags = strings.Fields(hashtags)
+	}
+	if mentions != "" {
+		err = json.Unmarshal([]byte(mentions), &chirp.Mentions)
+		if err != nil {
+			return Chirp{}, fmt.Errorf("unable to decode mentions of chirp %d: %s", chirp.Id, err)
+		}
+	}
+	chirp.CreatedAt = time.Unix(0, createdAt)
+	chirp.UpdatedAt = time.Unix(0, updatedAt)
+
+	if editedAt.Valid {
+		t := time.Unix(0, editedAt.Int64)
+		chirp.EditedAt = &t
+	}
+	if deletedAt.Valid {
+		t := time.Unix(0, deletedAt.Int64)
+		chirp.DeletedAt = &t
+	}
+
+	return chirp, nil
+}
+
+func (db *SQLiteDB) queryChirp(query string, args ...any) (Chirp, error) {
+	return scanChirp(db.conn.QueryRow(query, args...))
+}
+
+func (db *SQLiteDB) queryChirps(query string, args ...any) ([]Chirp, error) {
+	return queryChirps(db.conn, query, args...)
+}
+
+func queryChirps(q sqlQueryer, query string, args ...any) ([]Chirp, error) {
+	rows, err := q.Query(query, args...)
+	if err != nil {
+		return []Chirp{}, err
+	}
+	defer rows.Close()
+
+	chirps := make([]Chirp, 0)
+	for rows.Next() {
+		chirp, err := scanChirp(rows)
+		if err != nil {
+			return []Chirp{}, err
+		}
+		chirps = append(chirps, chirp)
+	}
+
+	return chirps, rows.Err()
+}
+
+func (db *SQLiteDB) FlagChirp(id int, rules []string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	chirp, err := scanChirp(tx.QueryRow(`SELECT `+sqliteChirpColumns+` FROM chirps WHERE id = ? AND deleted_at IS NULL`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: no chirp matching the id %d", ErrChirpNotFound, id)
+	}
+	if err != nil {
+		return err
+	}
+
+	err = recordSQLiteEvent(tx, EventChirpFlagged, flagEvent(chirp, rules))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
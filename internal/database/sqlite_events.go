@@ -0,0 +1,79 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// sqlExecer is satisfied by both *sql.DB and *sql.Tx
+type sqlExecer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// recordSQLiteEvent appends an event to the feed, pass the transaction
+// making the change so both commit together
+func recordSQLiteEvent(tx sqlExecer, eventType string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("unable to encode %s event: %s", eventType, err)
+	}
+
+	_, err = tx.Exec(`INSERT INTO events (type, time, data) VALUES (?, ?, ?)`, eventType, time.Now().UnixNano(), string(payload))
+	if err != nil {
+		return fmt.Errorf("unable to record %s event: %s", eventType, err)
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) GetEvents(after, limit int) ([]Event, error) {
+	if after != 0 {
+		// the seq of the last event ever recorded is kept by AUTOINCREMENT
+		// after the event itself is purged
+		var oldest int
+		err := db.conn.QueryRow(`SELECT COALESCE(
+			(SELECT MIN(seq) FROM events),
+			(SELECT seq + 1 FROM sqlite_sequence WHERE name = 'events'),
+			1)`).Scan(&oldest)
+		if err != nil {
+			return []Event{}, err
+		}
+		if after < oldest-1 {
+			return []Event{}, ErrEventsPurged
+		}
+	}
+
+	rows, err := db.conn.Query(`SELECT seq, type, time, data FROM events WHERE seq > ? ORDER BY seq LIMIT ?`, after, limit)
+	if err != nil {
+		return []Event{}, err
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		event := Event{}
+		var at int64
+		var data string
+		err = rows.Scan(&event.Seq, &event.Type, &at, &data)
+		if err != nil {
+			return []Event{}, err
+		}
+		event.Time = time.Unix(0, at)
+		event.Data = json.RawMessage(data)
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func (db *SQLiteDB) PurgeEvents(before time.Time) (int, error) {
+	res, err := db.conn.Exec(`DELETE FROM events WHERE time < ?`, before.UnixNano())
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	return int(n), err
+}
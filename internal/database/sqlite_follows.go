@@ -0,0 +1,140 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+func (db *SQLiteDB) Follow(followerID, followeeID int) (bool, error) {
+	if followerID == followeeID {
+		return false, ErrFollowSelf
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	err = checkSQLiteUser(tx, followeeID)
+	if err != nil {
+		return false, err
+	}
+
+	follow := Follow{
+		FollowerID: followerID,
+		FolloweeID: followeeID,
+		CreatedAt:  time.Now(),
+	}
+	res, err := tx.Exec(
+		`INSERT INTO follows (follower_id, followee_id, created_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`,
+		followerID, followeeID, follow.CreatedAt.UnixNano(),
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+
+	err = recordSQLiteEvent(tx, EventUserFollowed, follow)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (db *SQLiteDB) Unfollow(followerID, followeeID int) (bool, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	err = checkSQLiteUser(tx, followeeID)
+	if err != nil {
+		return false, err
+	}
+
+	follow := Follow{FollowerID: followerID, FolloweeID: followeeID}
+	var createdAt int64
+	err = tx.QueryRow(
+		`DELETE FROM follows WHERE follower_id = ? AND followee_id = ? RETURNING created_at`,
+		followerID, followeeID,
+	).Scan(&createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	follow.CreatedAt = time.Unix(0, createdAt)
+
+	err = recordSQLiteEvent(tx, EventUserUnfollowed, follow)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (db *SQLiteDB) GetFollowers(userID, offset, limit int) ([]Follow, error) {
+	return db.queryFollows(userID,
+		`SELECT follower_id, followee_id, created_at FROM follows WHERE followee_id = ?
+		ORDER BY created_at DESC, follower_id DESC LIMIT ? OFFSET ?`,
+		userID, limit, offset,
+	)
+}
+
+func (db *SQLiteDB) GetFollowing(userID, offset, limit int) ([]Follow, error) {
+	return db.queryFollows(userID,
+		`SELECT follower_id, followee_id, created_at FROM follows WHERE follower_id = ?
+		ORDER BY created_at DESC, followee_id DESC LIMIT ? OFFSET ?`,
+		userID, limit, offset,
+	)
+}
+
+// queryFollows runs a query selecting follows involving userID
+func (db *SQLiteDB) queryFollows(userID int, query string, args ...any) ([]Follow, error) {
+	_, err := db.GetUserByID(userID)
+	if err != nil {
+		return []Follow{}, err
+	}
+
+	rows, err := db.conn.Query(query, args...)
+	if err != nil {
+		return []Follow{}, err
+	}
+	defer rows.Close()
+
+	follows := []Follow{}
+	for rows.Next() {
+		follow := Follow{}
+		var createdAt int64
+		err = rows.Scan(&follow.FollowerID, &follow.FolloweeID, &createdAt)
+		if err != nil {
+			return []Follow{}, err
+		}
+		follow.CreatedAt = time.Unix(0, createdAt)
+		follows = append(follows, follow)
+	}
+
+	return follows, rows.Err()
+}
+
+// checkSQLiteUser checks a user exists
+func checkSQLiteUser(tx *sql.Tx, userID int) error {
+	var exists bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE id = ?)`, userID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func (db *SQLiteDB) LikeChirp(chirpID, userID int) (bool, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	err = checkLiveChirp(tx, chirpID)
+	if err != nil {
+		return false, err
+	}
+
+	like := Like{
+		ChirpID:   chirpID,
+		UserID:    userID,
+		CreatedAt: time.Now(),
+	}
+	res, err := tx.Exec(
+		`INSERT INTO likes (chirp_id, user_id, created_at) VALUES (?, ?, ?) ON CONFLICT DO NOTHING`,
+		chirpID, userID, like.CreatedAt.UnixNano(),
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+
+	_, err = tx.Exec(`UPDATE chirps SET like_count = like_count + 1, version = version + 1 WHERE id = ?`, chirpID)
+	if err != nil {
+		return false, err
+	}
+
+	err = recordSQLiteEvent(tx, EventChirpLiked, like)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (db *SQLiteDB) UnlikeChirp(chirpID, userID int) (bool, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	err = checkLiveChirp(tx, chirpID)
+	if err != nil {
+		return false, err
+	}
+
+	like := Like{ChirpID: chirpID, UserID: userID}
+	var createdAt int64
+	err = tx.QueryRow(`DELETE FROM likes WHERE chirp_id = ? AND user_id = ? RETURNING created_at`, chirpID, userID).Scan(&createdAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	like.CreatedAt = time.Unix(0, createdAt)
+
+	_, err = tx.Exec(`UPDATE chirps SET like_count = like_count - 1, version = version + 1 WHERE id = ?`, chirpID)
+	if err != nil {
+		return false, err
+	}
+
+	err = recordSQLiteEvent(tx, EventChirpUnliked, like)
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+func (db *SQLiteDB) GetChirpLikes(chirpID, offset, limit int) ([]Like, error) {
+	_, err := db.GetChirpByID(chirpID)
+	if err != nil {
+		return []Like{}, err
+	}
+
+	rows, err := db.conn.Query(
+		`SELECT chirp_id, user_id, created_at FROM likes WHERE chirp_id = ?
+		ORDER BY created_at DESC, user_id DESC LIMIT ? OFFSET ?`,
+		chirpID, limit, offset,
+	)
+	if err != nil {
+		return []Like{}, err
+	}
+	defer rows.Close()
+
+	likes := []Like{}
+	for rows.Next() {
+		like := Like{}
+		var createdAt int64
+		err = rows.Scan(&like.ChirpID, &like.UserID, &createdAt)
+		if err != nil {
+			return []Like{}, err
+		}
+		like.CreatedAt = time.Unix(0, createdAt)
+		likes = append(likes, like)
+	}
+
+	return likes, rows.Err()
+}
+
+func (db *SQLiteDB) GetLikedChirps(userID, offset, limit int) ([]Chirp, error) {
+	return db.queryChirps(
+		`SELECT `+sqliteChirpColumns+` FROM chirps JOIN (
+			SELECT chirp_id, created_at AS liked_at FROM likes WHERE user_id = ?
+		) liked ON liked.chirp_id = chirps.id
+		WHERE deleted_at IS NULL
+		ORDER BY liked.liked_at DESC, liked.chirp_id DESC LIMIT ? OFFSET ?`,
+		userID, limit, offset,
+	)
+}
+
+// checkLiveChirp checks a chirp exists and has not been deleted
+func checkLiveChirp(tx *sql.Tx, chirpID int) error {
+	var exists bool
+	err := tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM chirps WHERE id = ? AND deleted_at IS NULL)`, chirpID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("%w: no chirp matching the id %d", ErrChirpNotFound, chirpID)
+	}
+
+	return nil
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+
+	_ "modernc.org/sqlite"
+)
+
+type SQLiteDB struct {
+	path string
+	conn *sql.DB
+}
+
+// create new sqlite db
+func NewSQLiteDB(path string) (*SQLiteDB, error) {
+	db, err := openSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+
+	applied, err := db.migrate(false)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	for _, name := range applied {
+		log.Printf("Applied db migration: %s", name)
+	}
+
+	return db, nil
+}
+
+func openSQLite(path string) (*SQLiteDB, error) {
+	conn, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open sqlite db: %s", err)
+	}
+	// sqlite only allows a single writer, serialise everything through one
+	// connection instead of fighting over SQLITE_BUSY
+	conn.SetMaxOpenConns(1)
+
+	return &SQLiteDB{path: path, conn: conn}, nil
+}
+
+func (db *SQLiteDB) Close() error {
+	return db.conn.Close()
+}
@@ -0,0 +1,50 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+)
+
+// sqliteMentionsIn resolves the mentions in body
+func sqliteMentionsIn(tx *sql.Tx, body string) ([]Mention, error) {
+	var lookupErr error
+	mentions := resolveMentions(body, func(handle string) (int, bool) {
+		var id int
+		err := tx.QueryRow(`SELECT id FROM users WHERE handle = ?`, handle).Scan(&id)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			lookupErr = err
+		}
+		return id, err == nil
+	})
+
+	return mentions, lookupErr
+}
+
+// encodeMentions is the value of the mentions column for mentions
+func encodeMentions(mentions []Mention) (string, error) {
+	if len(mentions) == 0 {
+		return "", nil
+	}
+
+	data, err := json.Marshal(mentions)
+	return string(data), err
+}
+
+// setSQLiteMentions replaces the rows of chirp_mentions for a chirp, the
+// mentions column of chirps is the caller's to keep in step
+func setSQLiteMentions(tx sqlExecer, chirpID int, mentions []Mention) error {
+	_, err := tx.Exec(`DELETE FROM chirp_mentions WHERE chirp_id = ?`, chirpID)
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range mentionedUsers(mentions) {
+		_, err = tx.Exec(`INSERT INTO chirp_mentions (user_id, chirp_id) VALUES (?, ?)`, userID, chirpID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
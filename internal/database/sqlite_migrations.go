@@ -0,0 +1,287 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+type sqliteMigration struct {
+	name string
+	sql  string
+	// up, when set, runs after sql for changes that need Go code
+	up func(tx *sql.Tx) error
+}
+
+// sqliteMigrations are applied in order; PRAGMA user_version records how
+// many of them have already run against the file.
+var sqliteMigrations = []sqliteMigration{
+	{
+		name: "create users, chirps and refresh_tokens",
+		sql: `CREATE TABLE users (
+			id            INTEGER PRIMARY KEY AUTOINCREMENT,
+			email         TEXT NOT NULL UNIQUE,
+			password      TEXT NOT NULL,
+			token         TEXT NOT NULL DEFAULT '',
+			is_chirpy_red INTEGER NOT NULL DEFAULT 0
+		);
+		CREATE TABLE chirps (
+			id        INTEGER PRIMARY KEY AUTOINCREMENT,
+			body      TEXT NOT NULL,
+			author_id INTEGER NOT NULL
+		);
+		CREATE TABLE refresh_tokens (
+			token      TEXT PRIMARY KEY,
+			user_id    INTEGER NOT NULL,
+			expires_at INTEGER NOT NULL
+		);`,
+	},
+	{
+		name: "index chirp authors and refresh token owners",
+		sql: `CREATE INDEX chirps_author_id ON chirps (author_id);
+		CREATE INDEX refresh_tokens_user_id ON refresh_tokens (user_id);`,
+	},
+	{
+		name: "track refresh token revocation",
+		sql: `ALTER TABLE refresh_tokens ADD COLUMN revoked_at INTEGER;
+		CREATE INDEX refresh_tokens_expires_at ON refresh_tokens (expires_at);`,
+	},
+	{
+		name: "soft delete chirps",
+		sql: `ALTER TABLE chirps ADD COLUMN deleted_at INTEGER;
+		CREATE INDEX chirps_deleted_at ON chirps (deleted_at);`,
+	},
+	{
+		name: "version users and chirps",
+		sql: `ALTER TABLE users ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+		ALTER TABLE chirps ADD COLUMN version INTEGER NOT NULL DEFAULT 1;`,
+	},
+	{
+		name: "add the event log",
+		sql: `CREATE TABLE events (
+			seq  INTEGER PRIMARY KEY AUTOINCREMENT,
+			type TEXT NOT NULL,
+			time INTEGER NOT NULL,
+			data TEXT NOT NULL
+		);
+		CREATE INDEX events_time ON events (time);`,
+	},
+	{
+		// chirps written before this lose their real creation time
+		name: "timestamp chirps",
+		sql: `ALTER TABLE chirps ADD COLUMN created_at INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE chirps ADD COLUMN updated_at INTEGER NOT NULL DEFAULT 0;
+		UPDATE chirps SET created_at = unixepoch() * 1000000000;
+		UPDATE chirps SET updated_at = created_at;
+		CREATE INDEX chirps_created_at ON chirps (created_at, id);`,
+	},
+	{
+		// the triggers keep deleted chirps out of the index
+		name: "full-text index chirps",
+		sql: `CREATE VIRTUAL TABLE chirps_fts USING fts5(body, tokenize = 'unicode61');
+		INSERT INTO chirps_fts (rowid, body) SELECT id, body FROM chirps WHERE deleted_at IS NULL;
+		CREATE TRIGGER chirps_fts_insert AFTER INSERT ON chirps WHEN new.deleted_at IS NULL BEGIN
+			INSERT INTO chirps_fts (rowid, body) VALUES (new.id, new.body);
+		END;
+		CREATE TRIGGER chirps_fts_update AFTER UPDATE ON chirps BEGIN
+			DELETE FROM chirps_fts WHERE rowid = old.id;
+			INSERT INTO chirps_fts (rowid, body) SELECT new.id, new.body WHERE new.deleted_at IS NULL;
+		END;
+		CREATE TRIGGER chirps_fts_delete AFTER DELETE ON chirps BEGIN
+			DELETE FROM chirps_fts WHERE rowid = old.id;
+		END;`,
+	},
+	{
+		name: "keep chirp revisions",
+		sql: `ALTER TABLE chirps ADD COLUMN edited_at INTEGER;
+		CREATE TABLE chirp_revisions (
+			chirp_id   INTEGER NOT NULL,
+			revision   INTEGER NOT NULL,
+			body       TEXT NOT NULL,
+			created_at INTEGER NOT NULL,
+			PRIMARY KEY (chirp_id, revision)
+		);`,
+	},
+	{
+		name: "thread replies",
+		sql: `ALTER TABLE chirps ADD COLUMN in_reply_to INTEGER;
+		ALTER TABLE chirps ADD COLUMN reply_count INTEGER NOT NULL DEFAULT 0;
+		CREATE INDEX chirps_in_reply_to ON chirps (in_reply_to);`,
+	},
+	{
+		name: "add likes",
+		sql: `ALTER TABLE chirps ADD COLUMN like_count INTEGER NOT NULL DEFAULT 0;
+		CREATE TABLE likes (
+			chirp_id   INTEGER NOT NULL,
+			user_id    INTEGER NOT NULL,
+			created_at INTEGER NOT NULL,
+			PRIMARY KEY (chirp_id, user_id)
+		);
+		CREATE INDEX likes_user_id ON likes (user_id, created_at);`,
+	},
+	{
+		name: "rechirps and quotes",
+		sql: `ALTER TABLE chirps ADD COLUMN rechirp_of INTEGER;
+		ALTER TABLE chirps ADD COLUMN quote_of INTEGER;
+		ALTER TABLE chirps ADD COLUMN rechirp_count INTEGER NOT NULL DEFAULT 0;
+		ALTER TABLE chirps ADD COLUMN quote_count INTEGER NOT NULL DEFAULT 0;
+		CREATE INDEX chirps_rechirp_of ON chirps (rechirp_of, author_id);
+		CREATE INDEX chirps_quote_of ON chirps (quote_of);`,
+	},
+	{
+		name: "add follows",
+		sql: `CREATE TABLE follows (
+			follower_id INTEGER NOT NULL,
+			followee_id INTEGER NOT NULL,
+			created_at  INTEGER NOT NULL,
+			PRIMARY KEY (follower_id, followee_id)
+		);
+		CREATE INDEX follows_followee_id ON follows (followee_id, created_at);`,
+	},
+	{
+		name: "extract hashtags",
+		sql: `ALTER TABLE chirps ADD COLUMN hashtags TEXT NOT NULL DEFAULT '';
+		CREATE TABLE chirp_hashtags (
+			tag      TEXT NOT NULL,
+			chirp_id INTEGER NOT NULL,
+			PRIMARY KEY (tag, chirp_id)
+		);
+		CREATE INDEX chirp_hashtags_chirp_id ON chirp_hashtags (chirp_id);`,
+		up: migrateSQLiteHashtags,
+	},
+	{
+		name: "user handles and mentions",
+		sql: `ALTER TABLE users ADD COLUMN handle TEXT;
+		CREATE UNIQUE INDEX users_handle ON users (handle);
+		ALTER TABLE chirps ADD COLUMN mentions TEXT NOT NULL DEFAULT '';
+		CREATE TABLE chirp_mentions (
+			user_id  INTEGER NOT NULL,
+			chirp_id INTEGER NOT NULL,
+			PRIMARY KEY (user_id, chirp_id)
+		);
+		CREATE INDEX chirp_mentions_chirp_id ON chirp_mentions (chirp_id);`,
+	},
+	{
+		name: "user profiles",
+		sql: `ALTER TABLE users ADD COLUMN display_name TEXT NOT NULL DEFAULT '';
+		ALTER TABLE users ADD COLUMN bio TEXT NOT NULL DEFAULT '';
+		ALTER TABLE users ADD COLUMN avatar_url TEXT NOT NULL DEFAULT '';`,
+	},
+}
+
+// MigrateSQLiteDB is MigrateDB for the sqlite store. A dry run applies the
+// migrations inside a transaction that is then rolled back.
+func MigrateSQLiteDB(path string, dryRun bool) ([]string, error) {
+	db, err := openSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	return db.migrate(dryRun)
+}
+
+func (db *SQLiteDB) migrate(dryRun bool) ([]string, error) {
+	_, err := db.conn.Exec(`PRAGMA journal_mode = WAL; PRAGMA foreign_keys = ON;`)
+	if err != nil {
+		return nil, fmt.Errorf("unable to configure sqlite db: %s", err)
+	}
+
+	var version int
+	err = db.conn.QueryRow(`PRAGMA user_version`).Scan(&version)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read sqlite schema version: %s", err)
+	}
+	if version > len(sqliteMigrations) {
+		return nil, fmt.Errorf(
+			"db schema version %d is newer than the latest supported version %d",
+			version, len(sqliteMigrations),
+		)
+	}
+	if version == len(sqliteMigrations) {
+		return nil, nil
+	}
+
+	if version > 0 && !dryRun {
+		backupPath := fmt.Sprintf("%s.v%d.%s.bak", db.path, version, time.Now().UTC().Format("20060102T150405Z"))
+		_, err = db.conn.Exec(`VACUUM INTO ?`, backupPath)
+		if err != nil {
+			return nil, fmt.Errorf("unable to write migration backup: %s", err)
+		}
+		log.Printf("Backed up db to %s before migrating", backupPath)
+	}
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	applied := []string{}
+	for i := version; i < len(sqliteMigrations); i++ {
+		m := sqliteMigrations[i]
+		_, err = tx.Exec(m.sql)
+		if err == nil && m.up != nil {
+			err = m.up(tx)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("migration %d (%s) failed: %s", i+1, m.name, err)
+		}
+		applied = append(applied, fmt.Sprintf("%d: %s", i+1, m.name))
+	}
+
+	if dryRun {
+		return applied, nil
+	}
+
+	_, err = tx.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, len(sqliteMigrations)))
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+
+	return applied, nil
+}
+
+func migrateSQLiteHashtags(tx *sql.Tx) error {
+	rows, err := tx.Query(`SELECT id, body FROM chirps`)
+	if err != nil {
+		return err
+	}
+
+	bodies := map[int]string{}
+	for rows.Next() {
+		var id int
+		var body string
+		err = rows.Scan(&id, &body)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		bodies[id] = body
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for id, body := range bodies {
+		hashtags := ExtractHashtags(body)
+		_, err = tx.Exec(`UPDATE chirps SET hashtags = ? WHERE id = ?`, strings.Join(hashtags, " "), id)
+		if err != nil {
+			return err
+		}
+		err = setSQLiteHashtags(tx, id, hashtags)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
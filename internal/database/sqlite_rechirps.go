@@ -0,0 +1,95 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func (db *SQLiteDB) Rechirp(chirpID, userID int) (Chirp, bool, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return Chirp{}, false, err
+	}
+	defer tx.Rollback()
+
+	originalID, err := sqliteOriginalOf(tx, chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Chirp{}, false, fmt.Errorf("%w: no chirp matching the id %d", ErrChirpNotFound, chirpID)
+	}
+	if err != nil {
+		return Chirp{}, false, err
+	}
+
+	rechirp, err := scanChirp(tx.QueryRow(
+		`SELECT `+sqliteChirpColumns+` FROM chirps WHERE rechirp_of = ? AND author_id = ? AND deleted_at IS NULL`,
+		originalID, userID,
+	))
+	if err == nil {
+		return rechirp, false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return Chirp{}, false, err
+	}
+
+	rechirp, err = insertSQLiteChirp(tx, Chirp{
+		AuthorID:  userID,
+		RechirpOf: originalID,
+	})
+	if err != nil {
+		return Chirp{}, false, err
+	}
+
+	return rechirp, true, tx.Commit()
+}
+
+func (db *SQLiteDB) Unrechirp(chirpID, userID int) (bool, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	originalID, err := sqliteOriginalOf(tx, chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, fmt.Errorf("%w: no chirp matching the id %d", ErrChirpNotFound, chirpID)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var id int
+	err = tx.QueryRow(
+		`SELECT id FROM chirps WHERE rechirp_of = ? AND author_id = ? AND deleted_at IS NULL`,
+		originalID, userID,
+	).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	err = trashSQLiteChirp(tx, id, time.Now())
+	if err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// sqliteOriginalOf returns the id of the chirp that id shares when it is a
+// rechirp, and id itself otherwise, or sql.ErrNoRows if that chirp has been
+// deleted
+func sqliteOriginalOf(tx *sql.Tx, id int) (int, error) {
+	var originalID int
+	err := tx.QueryRow(
+		`SELECT original.id FROM chirps
+		JOIN chirps original ON original.id = COALESCE(chirps.rechirp_of, chirps.id)
+		WHERE chirps.id = ? AND original.deleted_at IS NULL`,
+		id,
+	).Scan(&originalID)
+
+	return originalID, err
+}
@@ -0,0 +1,91 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+func (db *SQLiteDB) StoreRefreshToken(token string, userID int) error {
+	_, err := db.conn.Exec(
+		`INSERT OR REPLACE INTO refresh_tokens (token, user_id, expires_at) VALUES (?, ?, ?)`,
+		token, userID, time.Now().Add(time.Hour).UnixNano(),
+	)
+	return err
+}
+
+func (db *SQLiteDB) RevokeRefreshToken(token string) error {
+	err := db.revokeRefreshToken(token)
+	if err != nil {
+		return fmt.Errorf("unable to write revoked token to db")
+	}
+
+	return nil
+}
+
+func (db *SQLiteDB) revokeRefreshToken(token string) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	err = tx.QueryRow(`SELECT user_id FROM refresh_tokens WHERE token = ? AND revoked_at IS NULL`, token).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE refresh_tokens SET revoked_at = ? WHERE token = ?`, time.Now().UnixNano(), token)
+	if err != nil {
+		return err
+	}
+
+	err = recordSQLiteEvent(tx, EventTokenRevoked, tokenEventData{UserID: userID})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (db *SQLiteDB) GetUserByRefreshToken(tokenString string) (User, error) {
+	var userID int
+	var expiresAt int64
+	var revokedAt sql.NullInt64
+	err := db.conn.QueryRow(`SELECT user_id, expires_at, revoked_at FROM refresh_tokens WHERE token = ?`, tokenString).
+		Scan(&userID, &expiresAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, fmt.Errorf("Token does not exist")
+	}
+	if err != nil {
+		return User{}, err
+	}
+
+	if revokedAt.Valid {
+		return User{}, fmt.Errorf("token has been revoked")
+	}
+
+	if time.Unix(0, expiresAt).Before(time.Now()) {
+		return User{}, fmt.Errorf("token has expired")
+	}
+
+	return db.GetUserByID(userID)
+}
+
+func (db *SQLiteDB) PurgeStaleRefreshTokens(now time.Time) (int, error) {
+	res, err := db.conn.Exec(
+		`DELETE FROM refresh_tokens WHERE revoked_at IS NOT NULL OR expires_at < ?`,
+		now.UnixNano(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := res.RowsAffected()
+	return int(n), err
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+func (db *SQLiteDB) EditChirp(id, version int, body string) (Chirp, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return Chirp{}, err
+	}
+	defer tx.Rollback()
+
+	chirp, err := scanChirp(tx.QueryRow(`SELECT `+sqliteChirpColumns+` FROM chirps WHERE id = ? AND deleted_at IS NULL`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Chirp{}, fmt.Errorf("%w: no chirp matching the id %d", ErrChirpNotFound, id)
+	}
+	if err != nil {
+		return Chirp{}, err
+	}
+	if !versionMatches(version, chirp.Version) {
+		return Chirp{}, ErrVersionMismatch
+	}
+	if chirp.RechirpOf != 0 {
+		return Chirp{}, ErrRechirpNotEditable
+	}
+
+	_, err = tx.Exec(
+		`INSERT INTO chirp_revisions (chirp_id, revision, body, created_at)
+		SELECT ?, COUNT(*) + 1, ?, ? FROM chirp_revisions WHERE chirp_id = ?`,
+		id, chirp.Body, chirp.bodyWrittenAt().UnixNano(), id,
+	)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	now := time.Now()
+	hashtags := ExtractHashtags(body)
+	mentions, err := sqliteMentionsIn(tx, body)
+	if err != nil {
+		return Chirp{}, err
+	}
+	encodedMentions, err := encodeMentions(mentions)
+	if err != nil {
+		return Chirp{}, err
+	}
+	_, err = tx.Exec(
+		`UPDATE chirps SET body = ?, hashtags = ?, mentions = ?, edited_at = ?, updated_at = ?, version = version + 1 WHERE id = ?`,
+		body, strings.Join(hashtags, " "), encodedMentions, now.UnixNano(), now.UnixNano(), id,
+	)
+	if err != nil {
+		return Chirp{}, err
+	}
+	err = setSQLiteHashtags(tx, id, hashtags)
+	if err != nil {
+		return Chirp{}, err
+	}
+	err = setSQLiteMentions(tx, id, mentions)
+	if err != nil {
+		return Chirp{}, err
+	}
+	chirp.Body = body
+	chirp.Hashtags = hashtags
+	chirp.Mentions = mentions
+	chirp.EditedAt = &now
+	chirp.UpdatedAt = now
+	chirp.Version++
+
+	err = recordSQLiteEvent(tx, EventChirpEdited, chirp)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	return chirp, tx.Commit()
+}
+
+func (db *SQLiteDB) GetChirpRevisions(id int) ([]ChirpRevision, error) {
+	_, err := db.GetChirpByID(id)
+	if err != nil {
+		return []ChirpRevision{}, err
+	}
+
+	rows, err := db.conn.Query(`SELECT revision, body, created_at FROM chirp_revisions WHERE chirp_id = ? ORDER BY revision`, id)
+	if err != nil {
+		return []ChirpRevision{}, err
+	}
+	defer rows.Close()
+
+	revisions := []ChirpRevision{}
+	for rows.Next() {
+		revision := ChirpRevision{}
+		var createdAt int64
+		err = rows.Scan(&revision.Revision, &revision.Body, &createdAt)
+		if err != nil {
+			return []ChirpRevision{}, err
+		}
+		revision.CreatedAt = time.Unix(0, createdAt)
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
+}
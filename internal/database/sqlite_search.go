@@ -0,0 +1,30 @@
+package database
+
+import (
+	"strings"
+)
+
+func (db *SQLiteDB) SearchChirps(q SearchQuery, offset, limit int) ([]Chirp, error) {
+	return db.queryChirps(
+		`SELECT `+sqliteChirpColumns+` FROM chirps JOIN (
+			SELECT rowid, bm25(chirps_fts) AS rank FROM chirps_fts WHERE chirps_fts MATCH ?
+		) matches ON matches.rowid = chirps.id
+		ORDER BY matches.rank, chirps.id DESC LIMIT ? OFFSET ?`,
+		q.fts5(), limit, offset,
+	)
+}
+
+// fts5 renders q as an FTS5 query. Terms only ever hold letters and
+// digits, so quoting them is enough to keep them from being read as syntax.
+func (q SearchQuery) fts5() string {
+	clauses := make([]string, 0, len(q.clauses))
+	for _, clause := range q.clauses {
+		expr := `"` + strings.Join(clause.terms, " ") + `"`
+		if clause.prefix {
+			expr += `*`
+		}
+		clauses = append(clauses, expr)
+	}
+
+	return strings.Join(clauses, " ")
+}
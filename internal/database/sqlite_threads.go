@@ -0,0 +1,37 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+func (db *SQLiteDB) GetThread(id, limit int) ([]Chirp, error) {
+	_, err := db.GetChirpByID(id)
+	if err != nil {
+		return []Chirp{}, err
+	}
+
+	// walk up to the top of the reply chain, purged chirps end it early
+	var rootID int
+	err = db.conn.QueryRow(`WITH RECURSIVE chain (id, in_reply_to) AS (
+			SELECT id, in_reply_to FROM chirps WHERE id = ?
+			UNION ALL
+			SELECT c.id, c.in_reply_to FROM chirps c JOIN chain ON c.id = chain.in_reply_to
+		)
+		SELECT id FROM chain WHERE in_reply_to IS NULL OR in_reply_to NOT IN (SELECT id FROM chirps)`, id).Scan(&rootID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return []Chirp{}, fmt.Errorf("%w: no chirp matching the id %d", ErrChirpNotFound, id)
+	}
+	if err != nil {
+		return []Chirp{}, err
+	}
+
+	return db.queryChirps(`WITH RECURSIVE thread (id, depth) AS (
+			SELECT ?, 0
+			UNION ALL
+			SELECT c.id, thread.depth + 1 FROM chirps c JOIN thread ON c.in_reply_to = thread.id
+		)
+		SELECT `+sqliteChirpColumns+` FROM chirps JOIN thread USING (id)
+		ORDER BY thread.depth, chirps.in_reply_to, chirps.id LIMIT ?`, rootID, limit)
+}
@@ -0,0 +1,172 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+const sqliteUserColumns = `id, email, COALESCE(handle, ''), display_name, bio, avatar_url, password, token, is_chirpy_red, version`
+
+// CreateUsers checks the email and handle are free inside its transaction,
+// so a concurrent signup cannot take them between the check and the insert
+func (db *SQLiteDB) CreateUsers(user User) (User, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return User{}, err
+	}
+	defer tx.Rollback()
+
+	var taken bool
+	err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE email = ?)`, user.Email).Scan(&taken)
+	if err != nil {
+		return User{}, err
+	}
+	if taken {
+		return User{}, fmt.Errorf("user with email already exists")
+	}
+	if user.Handle != "" {
+		err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE handle = ?)`, user.Handle).Scan(&taken)
+		if err != nil {
+			return User{}, err
+		}
+		if taken {
+			return User{}, ErrHandleTaken
+		}
+	}
+
+	res, err := tx.Exec(
+		`INSERT INTO users (email, handle, password) VALUES (?, NULLIF(?, ''), ?)`,
+		user.Email, user.Handle, user.Password,
+	)
+	if err != nil {
+		return User{}, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return User{}, err
+	}
+
+	user = User{
+		Id:          int(id),
+		Email:       user.Email,
+		Handle:      user.Handle,
+		Password:    user.Password,
+		IsChirpyRed: false,
+		Version:     1,
+	}
+	err = recordSQLiteEvent(tx, EventUserCreated, userEvent(user))
+	if err != nil {
+		return User{}, err
+	}
+
+	return user, tx.Commit()
+}
+
+func (db *SQLiteDB) GetUserByID(id int) (User, error) {
+	return db.queryUser(`SELECT `+sqliteUserColumns+` FROM users WHERE id = ?`, id)
+}
+
+func (db *SQLiteDB) GetUserByEmail(email string) (User, error) {
+	return db.queryUser(`SELECT `+sqliteUserColumns+` FROM users WHERE email = ?`, email)
+}
+
+func (db *SQLiteDB) GetUserByHandle(handle string) (User, error) {
+	return db.queryUser(`SELECT `+sqliteUserColumns+` FROM users WHERE handle = ?`, handle)
+}
+
+func (db *SQLiteDB) UpdateUser(user User, version int) (User, error) {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return User{}, err
+	}
+	defer tx.Rollback()
+
+	current, err := scanUser(tx.QueryRow(`SELECT `+sqliteUserColumns+` FROM users WHERE id = ?`, user.Id))
+	if err != nil {
+		return User{}, err
+	}
+	if !versionMatches(version, current.Version) {
+		return User{}, ErrVersionMismatch
+	}
+
+	if user.Handle != "" {
+		var taken bool
+		err = tx.QueryRow(`SELECT EXISTS (SELECT 1 FROM users WHERE handle = ? AND id != ?)`, user.Handle, user.Id).Scan(&taken)
+		if err != nil {
+			return User{}, err
+		}
+		if taken {
+			return User{}, ErrHandleTaken
+		}
+	}
+
+	_, err = tx.Exec(
+		`UPDATE users SET email = ?, handle = NULLIF(?, ''), display_name = ?, bio = ?, avatar_url = ?, password = ?,
+		version = version + 1 WHERE id = ?`,
+		user.Email, user.Handle, user.DisplayName, user.Bio, user.AvatarURL, user.Password, user.Id,
+	)
+	if err != nil {
+		return User{}, err
+	}
+
+	user.Token = current.Token
+	user.IsChirpyRed = current.IsChirpyRed
+	user.Version = current.Version + 1
+
+	err = recordSQLiteEvent(tx, EventUserUpdated, userEvent(user))
+	if err != nil {
+		return User{}, err
+	}
+
+	return user, tx.Commit()
+}
+
+func (db *SQLiteDB) UpgradeUser(userID int) error {
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	user, err := scanUser(tx.QueryRow(`SELECT `+sqliteUserColumns+` FROM users WHERE id = ?`, userID))
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`UPDATE users SET is_chirpy_red = 1, version = version + 1 WHERE id = ?`, userID)
+	if err != nil {
+		return err
+	}
+	user.IsChirpyRed = true
+	user.Version++
+
+	err = recordSQLiteEvent(tx, EventUserUpgraded, userEvent(user))
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (db *SQLiteDB) queryUser(query string, args ...any) (User, error) {
+	return scanUser(db.conn.QueryRow(query, args...))
+}
+
+// scanUser reads a row selected with sqliteUserColumns
+func scanUser(row rowScanner) (User, error) {
+	user := User{}
+	err := row.Scan(
+		&user.Id, &user.Email, &user.Handle, &user.DisplayName, &user.Bio, &user.AvatarURL,
+		&user.Password, &user.Token, &user.IsChirpyRed, &user.Version,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return User{}, err
+	}
+
+	return user, nil
+}
@@ -0,0 +1,100 @@
+package database
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Store is the persistence layer used by the HTTP handlers. DB (a single
+// JSON file) and SQLiteDB (an embedded SQLite database) both implement it.
+type Store interface {
+	CreateChirp(chirp Chirp) (Chirp, error)
+	GetThread(id, limit int) ([]Chirp, error)
+	GetChirpByID(id int) (Chirp, error)
+	ListChirps(q ChirpQuery) ([]Chirp, error)
+	SearchChirps(q SearchQuery, offset, limit int) ([]Chirp, error)
+	TrendingHashtags(q TrendingQuery) ([]TrendingHashtag, error)
+	EditChirp(id, version int, body string) (Chirp, error)
+	GetChirpRevisions(id int) ([]ChirpRevision, error)
+	DeleteChirpByID(id, version int) error
+	GetDeletedChirpByID(id int) (Chirp, error)
+	RestoreChirpByID(id int, deletedSince time.Time) (Chirp, error)
+	PurgeDeletedChirps(deletedBefore time.Time) (int, error)
+	FlagChirp(id int, rules []string) error
+
+	Rechirp(chirpID, userID int) (Chirp, bool, error)
+	Unrechirp(chirpID, userID int) (bool, error)
+
+	LikeChirp(chirpID, userID int) (bool, error)
+	UnlikeChirp(chirpID, userID int) (bool, error)
+	GetChirpLikes(chirpID, offset, limit int) ([]Like, error)
+	GetLikedChirps(userID, offset, limit int) ([]Chirp, error)
+
+	CreateUsers(user User) (User, error)
+	GetUserByID(id int) (User, error)
+	GetUserByEmail(email string) (User, error)
+	GetUserByHandle(handle string) (User, error)
+	UpdateUser(user User, version int) (User, error)
+	UpgradeUser(userID int) error
+
+	Follow(followerID, followeeID int) (bool, error)
+	Unfollow(followerID, followeeID int) (bool, error)
+	GetFollowers(userID, offset, limit int) ([]Follow, error)
+	GetFollowing(userID, offset, limit int) ([]Follow, error)
+
+	StoreRefreshToken(token string, userID int) error
+	RevokeRefreshToken(token string) error
+	GetUserByRefreshToken(tokenString string) (User, error)
+	PurgeStaleRefreshTokens(now time.Time) (int, error)
+
+	GetEvents(after, limit int) ([]Event, error)
+	PurgeEvents(before time.Time) (int, error)
+
+	Backup(w io.Writer) error
+	Restore(r io.Reader) error
+
+	Close() error
+}
+
+var (
+	_ Store = (*DB)(nil)
+	_ Store = (*SQLiteDB)(nil)
+)
+
+// Open opens the store selected by driver ("json" or "sqlite") at path.
+// opts only apply to the json store.
+func Open(driver, path string, opts Options) (Store, error) {
+	switch driver {
+	case "", "json":
+		db, err := NewDBWithOptions(path, opts)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	case "sqlite":
+		if opts.EncryptionKey != nil {
+			return nil, ErrEncryptionNotSupported
+		}
+		db, err := NewSQLiteDB(path)
+		if err != nil {
+			return nil, err
+		}
+		return db, nil
+	default:
+		return nil, fmt.Errorf("unknown database driver: %s", driver)
+	}
+}
+
+// Migrate applies pending schema migrations to the store selected by
+// driver, see MigrateDB
+func Migrate(driver, path string, opts Options, dryRun bool) ([]string, error) {
+	switch driver {
+	case "", "json":
+		return MigrateDB(path, opts, dryRun)
+	case "sqlite":
+		return MigrateSQLiteDB(path, dryRun)
+	default:
+		return nil, fmt.Errorf("unknown database driver: %s", driver)
+	}
+}
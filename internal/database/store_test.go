@@ -0,0 +1,138 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// testStores opens a fresh store per driver for fn
+func testStores(t *testing.T, fn func(t *testing.T, open func() Store)) {
+	for _, driver := range []string{"json", "sqlite"} {
+		t.Run(driver, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "database")
+			fn(t, func() Store {
+				store, err := Open(driver, path, Options{})
+				if err != nil {
+					t.Fatalf("unable to open %s store: %s", driver, err)
+				}
+				return store
+			})
+		})
+	}
+}
+
+func TestConcurrentCreatesKeepEveryWrite(t *testing.T) {
+	const writers, perWriter = 8, 25
+
+	testStores(t, func(t *testing.T, open func() Store) {
+		store := open()
+
+		var wg sync.WaitGroup
+		var mux sync.Mutex
+		userIDs := map[int]bool{}
+		chirpIDs := map[int]bool{}
+		errs := make(chan error, writers)
+		for w := 0; w < writers; w++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for i := 0; i < perWriter; i++ {
+					user, err := store.CreateUsers(User{Email: fmt.Sprintf("%d-%d@example.com", w, i), Password: "hash"})
+					if err != nil {
+						errs <- err
+						return
+					}
+					chirp, err := store.CreateChirp(Chirp{Body: fmt.Sprintf("chirp %d-%d", w, i), AuthorID: user.Id})
+					if err != nil {
+						errs <- err
+						return
+					}
+
+					mux.Lock()
+					if userIDs[user.Id] {
+						t.Errorf("user id %d handed out twice", user.Id)
+					}
+					if chirpIDs[chirp.Id] {
+						t.Errorf("chirp id %d handed out twice", chirp.Id)
+					}
+					userIDs[user.Id] = true
+					chirpIDs[chirp.Id] = true
+					mux.Unlock()
+				}
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			t.Fatalf("concurrent create failed: %s", err)
+		}
+
+		err := store.Close()
+		if err != nil {
+			t.Fatalf("unable to close store: %s", err)
+		}
+
+		store = open()
+		defer store.Close()
+
+		chirps, err := store.ListChirps(ChirpQuery{})
+		if err != nil {
+			t.Fatalf("unable to list chirps: %s", err)
+		}
+		if len(chirps) != writers*perWriter {
+			t.Errorf("got %d chirps after reopening, want %d", len(chirps), writers*perWriter)
+		}
+		for _, chirp := range chirps {
+			if !chirpIDs[chirp.Id] {
+				t.Errorf("chirp %d was never handed out", chirp.Id)
+			}
+		}
+		for id := range userIDs {
+			_, err := store.GetUserByID(id)
+			if err != nil {
+				t.Errorf("user %d was lost: %s", id, err)
+			}
+		}
+	})
+}
+
+func TestConcurrentSignupsForOneHandle(t *testing.T) {
+	const signups = 64
+
+	testStores(t, func(t *testing.T, open func() Store) {
+		store := open()
+		defer store.Close()
+
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		errs := make(chan error, signups)
+		for i := 0; i < signups; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				_, err := store.CreateUsers(User{Email: fmt.Sprintf("%d@example.com", i), Handle: "taken", Password: "hash"})
+				errs <- err
+			}()
+		}
+		close(start)
+		wg.Wait()
+		close(errs)
+
+		created := 0
+		for err := range errs {
+			switch {
+			case err == nil:
+				created++
+			case !errors.Is(err, ErrHandleTaken):
+				t.Errorf("got %v, want ErrHandleTaken", err)
+			}
+		}
+		if created != 1 {
+			t.Errorf("%d signups got the handle, want 1", created)
+		}
+	})
+}
@@ -0,0 +1,165 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// txLog records the rows an Update writes. Only those rows are journaled
+// and reindexed, and they are put back as they were if the transaction
+// fails, so a write costs the same however large the db has grown.
+type txLog struct {
+	changes []rowChange
+	touched map[string]bool
+}
+
+// rowChange is a row as it was before the transaction first wrote it,
+// existed is false when there was no such row
+type rowChange struct {
+	table   string
+	key     string
+	old     any
+	existed bool
+	// current reads the row as the transaction left it
+	current func() (any, bool)
+	undo    func()
+}
+
+func newTxLog() *txLog {
+	return &txLog{touched: map[string]bool{}}
+}
+
+// putRow sets rows[key], recording the change on the transaction of
+// dbStructure. Tables must only be written through putRow and deleteRow.
+func putRow[K comparable, V any](dbStructure *DBStructure, table string, rows map[K]V, key K, val V) {
+	touch(dbStructure.tx, table, rows, key)
+	rows[key] = val
+}
+
+func deleteRow[K comparable, V any](dbStructure *DBStructure, table string, rows map[K]V, key K) {
+	touch(dbStructure.tx, table, rows, key)
+	delete(rows, key)
+}
+
+// touch remembers rows[key] as it is before its first write, outside a
+// transaction there is nothing to remember
+func touch[K comparable, V any](log *txLog, table string, rows map[K]V, key K) {
+	if log == nil {
+		return
+	}
+	id := fmt.Sprintf("%s/%v", table, key)
+	if log.touched[id] {
+		return
+	}
+	log.touched[id] = true
+
+	old, existed := rows[key]
+	log.changes = append(log.changes, rowChange{
+		table:   table,
+		key:     fmt.Sprint(key),
+		old:     old,
+		existed: existed,
+		current: func() (any, bool) {
+			val, ok := rows[key]
+			return val, ok
+		},
+		undo: func() {
+			if existed {
+				rows[key] = old
+			} else {
+				delete(rows, key)
+			}
+		},
+	})
+}
+
+// entries returns the journal entries that redo the transaction
+func (log *txLog) entries() ([]journalEntry, error) {
+	entries := []journalEntry{}
+	for _, change := range log.changes {
+		val, ok := change.current()
+		if !ok {
+			if change.existed {
+				entries = append(entries, journalEntry{Op: journalOpDelete, Table: change.table, Key: change.key})
+			}
+			continue
+		}
+		if change.existed && reflect.DeepEqual(change.old, val) {
+			continue
+		}
+
+		data, err := json.Marshal(val)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, journalEntry{Op: journalOpPut, Table: change.table, Key: change.key, Value: data})
+	}
+
+	return entries, nil
+}
+
+// rollback puts every row the transaction wrote back as it was
+func (log *txLog) rollback() {
+	for i := len(log.changes) - 1; i >= 0; i-- {
+		log.changes[i].undo()
+	}
+}
+
+// the tables of DBStructure, by the name they are journaled under
+
+func (dbStructure *DBStructure) putChirp(chirp Chirp) {
+	putRow(dbStructure, "chirps", dbStructure.Chirps, chirp.Id, chirp)
+}
+
+func (dbStructure *DBStructure) deleteChirp(id int) {
+	deleteRow(dbStructure, "chirps", dbStructure.Chirps, id)
+}
+
+func (dbStructure *DBStructure) putUser(user User) {
+	putRow(dbStructure, "users", dbStructure.Users, user.Id, user)
+}
+
+func (dbStructure *DBStructure) putRefreshToken(token RefreshToken) {
+	putRow(dbStructure, "refresh_tokens", dbStructure.RefreshTokens, token.Token, token)
+}
+
+func (dbStructure *DBStructure) deleteRefreshToken(token string) {
+	deleteRow(dbStructure, "refresh_tokens", dbStructure.RefreshTokens, token)
+}
+
+func (dbStructure *DBStructure) putSequence(table string, id int) {
+	putRow(dbStructure, "sequences", dbStructure.Sequences, table, id)
+}
+
+func (dbStructure *DBStructure) putEvent(event Event) {
+	putRow(dbStructure, "events", dbStructure.Events, event.Seq, event)
+}
+
+func (dbStructure *DBStructure) deleteEvent(seq int) {
+	deleteRow(dbStructure, "events", dbStructure.Events, seq)
+}
+
+func (dbStructure *DBStructure) putRevisions(chirpID int, revisions []ChirpRevision) {
+	putRow(dbStructure, "revisions", dbStructure.Revisions, chirpID, revisions)
+}
+
+func (dbStructure *DBStructure) deleteRevisions(chirpID int) {
+	deleteRow(dbStructure, "revisions", dbStructure.Revisions, chirpID)
+}
+
+func (dbStructure *DBStructure) putLike(like Like) {
+	putRow(dbStructure, "likes", dbStructure.Likes, likeKey(like.ChirpID, like.UserID), like)
+}
+
+func (dbStructure *DBStructure) deleteLike(key string) {
+	deleteRow(dbStructure, "likes", dbStructure.Likes, key)
+}
+
+func (dbStructure *DBStructure) putFollow(follow Follow) {
+	putRow(dbStructure, "follows", dbStructure.Follows, followKey(follow.FollowerID, follow.FolloweeID), follow)
+}
+
+func (dbStructure *DBStructure) deleteFollow(key string) {
+	deleteRow(dbStructure, "follows", dbStructure.Follows, key)
+}
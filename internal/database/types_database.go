@@ -8,30 +8,116 @@ import (
 type DB struct {
 	path string
 	mux  *sync.RWMutex
+	opts Options
+	key  *cipherKey
+
+	// data is the whole database, writes go through Update
+	data  DBStructure
+	idx   *indexes
+	dirty bool
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
 }
 
 type Chirp struct {
 	Id       int    `json:"id"`
 	Body     string `json:"body"`
 	AuthorID int    `json:"author_id"`
+	// InReplyTo is the id of the chirp this one replies to, 0 if none
+	InReplyTo int `json:"in_reply_to,omitempty"`
+	// RechirpOf is the id of the chirp this one shares, a rechirp has no
+	// body of its own and goes to the trash along with the chirp it shares
+	RechirpOf int `json:"rechirp_of,omitempty"`
+	// QuoteOf is the id of the chirp this one quotes. Quotes outlive the
+	// chirp they quote, which then can no longer be fetched.
+	QuoteOf int `json:"quote_of,omitempty"`
+	// Hashtags are the tags in Body, see ExtractHashtags
+	Hashtags []string `json:"hashtags,omitempty"`
+	// Mentions are the users mentioned in Body, resolved when it was
+	// written
+	Mentions []Mention `json:"mentions,omitempty"`
+	// ReplyCount, RechirpCount and QuoteCount count the chirps referring
+	// to this one that have not been deleted
+	ReplyCount   int       `json:"reply_count"`
+	RechirpCount int       `json:"rechirp_count"`
+	QuoteCount   int       `json:"quote_count"`
+	LikeCount    int       `json:"like_count"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	// EditedAt is set once the body has been changed, see ChirpRevision
+	EditedAt  *time.Time `json:"edited_at,omitempty"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	// Version is bumped on every write, see AnyVersion
+	Version int `json:"version"`
+}
+
+// bodyWrittenAt is when the current body of the chirp was written
+func (chirp Chirp) bodyWrittenAt() time.Time {
+	if chirp.EditedAt != nil {
+		return *chirp.EditedAt
+	}
+	return chirp.CreatedAt
 }
 
 type DBStructure struct {
+	SchemaVersion int                     `json:"schema_version"`
 	Chirps        map[int]Chirp           `json:"chirps"`
 	Users         map[int]User            `json:"users"`
 	RefreshTokens map[string]RefreshToken `json:"refresh_tokens"`
+	// Sequences holds the last ID handed out per table, IDs are never reused
+	Sequences map[string]int `json:"sequences"`
+	// Events is the change feed, keyed by Seq
+	Events map[int]Event `json:"events"`
+	// Revisions holds the earlier bodies of edited chirps, keyed by chirp
+	// id and oldest first
+	Revisions map[int][]ChirpRevision `json:"revisions"`
+	// Likes is keyed by likeKey
+	Likes map[string]Like `json:"likes"`
+	// Follows is keyed by followKey
+	Follows map[string]Follow `json:"follows"`
+
+	// tx records the writes of the Update in progress, see putRow
+	tx *txLog
 }
 
-type RefreshToken struct {
+type Like struct {
+	ChirpID   int       `json:"chirp_id"`
 	UserID    int       `json:"user_id"`
-	Token     string    `json:"token"`
-	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Follow struct {
+	FollowerID int       `json:"follower_id"`
+	FolloweeID int       `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ChirpRevision is a body a chirp had before it was edited
+type ChirpRevision struct {
+	Revision  int       `json:"revision"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type RefreshToken struct {
+	UserID    int        `json:"user_id"`
+	Token     string     `json:"token"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
 }
 
 type User struct {
 	Id          int    `json:"id"`
 	Email       string `json:"email"`
+	Handle      string `json:"handle,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	Bio         string `json:"bio,omitempty"`
+	AvatarURL   string `json:"avatar_url,omitempty"`
 	Password    string `json:"password"`
 	Token       string `json:"token"`
 	IsChirpyRed bool   `json:"is_chirpy_red"`
+	// Version is bumped on every write, see AnyVersion
+	Version int `json:"version"`
 }
@@ -0,0 +1,143 @@
+// Package moderation checks chirp bodies against a chain of filters. Each
+// filter reports the stretches of a body its rules catch and what should be
+// done about them: mask them, reject the chirp, or flag it for review.
+package moderation
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+type Action string
+
+const (
+	// ActionMask replaces what the rule caught with asterisks
+	ActionMask Action = "mask"
+	// ActionReject refuses the chirp
+	ActionReject Action = "reject"
+	// ActionFlag lets the chirp through as is and queues it for review
+	ActionFlag Action = "flag"
+)
+
+const maskText = "****"
+
+// ParseAction accepts the name of an action, mask when name is empty
+func ParseAction(name string) (Action, error) {
+	switch action := Action(strings.ToLower(name)); action {
+	case "":
+		return ActionMask, nil
+	case ActionMask, ActionReject, ActionFlag:
+		return action, nil
+	default:
+		return "", fmt.Errorf("unknown moderation action %q, use mask, reject or flag", name)
+	}
+}
+
+// Match is a stretch of a body caught by a rule. Start and End are byte
+// offsets into the body, End exclusive.
+type Match struct {
+	Rule   string
+	Action Action
+	Start  int
+	End    int
+}
+
+// Filter finds what a body breaks
+type Filter interface {
+	Check(body string) []Match
+}
+
+// FilterFunc lets a plain function be used as a Filter
+type FilterFunc func(body string) []Match
+
+func (f FilterFunc) Check(body string) []Match {
+	return f(body)
+}
+
+// Chain runs each of its filters in turn and reports all their matches
+type Chain []Filter
+
+func (c Chain) Check(body string) []Match {
+	matches := []Match{}
+	for _, filter := range c {
+		matches = append(matches, filter.Check(body)...)
+	}
+
+	return matches
+}
+
+// Result is the outcome of moderating a body
+type Result struct {
+	// Body is the body with the masked matches replaced
+	Body     string
+	Rejected []Match
+	Flagged  []Match
+}
+
+// Moderate runs filter over body and applies the mask matches
+func Moderate(filter Filter, body string) Result {
+	result := Result{
+		Body:     body,
+		Rejected: []Match{},
+		Flagged:  []Match{},
+	}
+
+	masks := []Match{}
+	for _, match := range filter.Check(body) {
+		switch match.Action {
+		case ActionReject:
+			result.Rejected = append(result.Rejected, match)
+		case ActionFlag:
+			result.Flagged = append(result.Flagged, match)
+		default:
+			masks = append(masks, match)
+		}
+	}
+	result.Body = mask(body, masks)
+
+	return result
+}
+
+// RuleNames returns the rules behind matches, each once
+func RuleNames(matches []Match) []string {
+	rules := []string{}
+	for _, match := range matches {
+		if !slices.Contains(rules, match.Rule) {
+			rules = append(rules, match.Rule)
+		}
+	}
+
+	return rules
+}
+
+// mask replaces each stretch covered by matches, overlapping ones merged,
+// with maskText
+func mask(body string, matches []Match) string {
+	if len(matches) == 0 {
+		return body
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].Start < matches[j].Start
+	})
+
+	var masked strings.Builder
+	pos := 0
+	for i := 0; i < len(matches); {
+		start, end := matches[i].Start, matches[i].End
+		for i++; i < len(matches) && matches[i].Start < end; i++ {
+			end = max(end, matches[i].End)
+		}
+		if start < pos {
+			start = pos
+		}
+
+		masked.WriteString(body[pos:start])
+		masked.WriteString(maskText)
+		pos = end
+	}
+	masked.WriteString(body[pos:])
+
+	return masked.String()
+}
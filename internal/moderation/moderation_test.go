@@ -0,0 +1,104 @@
+package moderation
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDefaultMasksDisguisedWords(t *testing.T) {
+	for body, want := range map[string]string{
+		"What a kerfuffle!":            "What a ****!",
+		"KÉRFÚFFLE":                    "****",
+		"kerfu\u0301ffle":              "****",
+		"k3rfuffl3":                    "****",
+		"ｋｅｒｆｕｆｆｌｅ":                    "****",
+		"ker\u200bfuffle":              "****",
+		"k.e.r.f.u.f.f.l.e is spelled": "**** is spelled",
+		"ask @kerfuffle":               "ask @****",
+		"Sharbert and fornax":          "**** and ****",
+		"kerfuffles and sharberts":     "kerfuffles and sharberts",
+		"a clean chirp":                "a clean chirp",
+	} {
+		result := Moderate(Default(), body)
+		if result.Body != want {
+			t.Errorf("%q moderated to %q, want %q", body, result.Body, want)
+		}
+		if len(result.Rejected) != 0 || len(result.Flagged) != 0 {
+			t.Errorf("%q was rejected or flagged: %+v", body, result)
+		}
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	chain, err := ParseRules(strings.NewReader(`
+		# comments and blank lines are skipped
+
+		kerfuffle
+		sharbert reject
+		/(?i)buy (cheap|now)/ flag
+	`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := Moderate(chain, "Kerfuffle! BUY NOW")
+	if result.Body != "****! BUY NOW" {
+		t.Errorf("got body %q", result.Body)
+	}
+	if len(result.Rejected) != 0 {
+		t.Errorf("got rejected %+v", result.Rejected)
+	}
+	if rules := RuleNames(result.Flagged); !slices.Equal(rules, []string{"(?i)buy (cheap|now)"}) {
+		t.Errorf("flagged by %v", rules)
+	}
+
+	result = Moderate(chain, "sh4rbert")
+	if rules := RuleNames(result.Rejected); !slices.Equal(rules, []string{"sharbert"}) {
+		t.Errorf("rejected by %v", rules)
+	}
+
+	for _, rules := range []string{"word explode", "word mask extra", "/unclosed", "/(/"} {
+		_, err := ParseRules(strings.NewReader(rules))
+		if err == nil {
+			t.Errorf("%q parsed", rules)
+		}
+	}
+}
+
+func TestWatchReloadsChangedRules(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rules.txt")
+	err := os.WriteFile(path, []byte("kerfuffle\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rules, err := LoadRules(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Watch(rules, 0)
+	if err == nil {
+		t.Error("watching every 0s was accepted")
+	}
+
+	watcher, err := Watch(rules, time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer watcher.Stop()
+
+	err = os.WriteFile(path, []byte("kerfuffle\nfornax reject\n"), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if len(Moderate(rules, "fornax").Rejected) > 0 {
+			return
+		}
+	}
+	t.Error("changed rules were not reloaded")
+}
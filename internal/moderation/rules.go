@@ -0,0 +1,165 @@
+package moderation
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RegexRule catches every match of Pattern in a body
+type RegexRule struct {
+	Pattern *regexp.Regexp
+	Action  Action
+}
+
+func (r RegexRule) Check(body string) []Match {
+	matches := []Match{}
+	for _, loc := range r.Pattern.FindAllStringIndex(body, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		matches = append(matches, Match{Rule: r.Pattern.String(), Action: r.Action, Start: loc[0], End: loc[1]})
+	}
+
+	return matches
+}
+
+// Default masks the words chirps have always had masked
+func Default() Filter {
+	return NewWordList(map[string]Action{
+		"kerfuffle": ActionMask,
+		"sharbert":  ActionMask,
+		"fornax":    ActionMask,
+	})
+}
+
+// ParseRules reads one rule per line, a word or a /regular expression/
+// optionally followed by its action, mask by default:
+//
+//	# comments and blank lines are skipped
+//	kerfuffle
+//	sharbert reject
+//	/(?i)buy (cheap|now)/ flag
+func ParseRules(r io.Reader) (Chain, error) {
+	words := map[string]Action{}
+	chain := Chain{}
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "/") {
+			end := strings.LastIndex(line, "/")
+			if end == 0 {
+				return nil, fmt.Errorf("line %d: regular expression is missing its closing /", n)
+			}
+			pattern, err := regexp.Compile(line[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", n, err)
+			}
+			action, err := ParseAction(strings.TrimSpace(line[end+1:]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %s", n, err)
+			}
+			chain = append(chain, RegexRule{Pattern: pattern, Action: action})
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) > 2 {
+			return nil, fmt.Errorf("line %d: expected a word and an action", n)
+		}
+		action, err := ParseAction(strings.Join(fields[1:], ""))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %s", n, err)
+		}
+		words[fields[0]] = action
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return append(Chain{NewWordList(words)}, chain...), nil
+}
+
+// Rules is a Filter reading its rules from a file, which picks up changes
+// to the file on Reload
+type Rules struct {
+	path string
+
+	mux     sync.RWMutex
+	filter  Filter
+	modTime time.Time
+	size    int64
+}
+
+// LoadRules reads the rules in the file at path, see ParseRules for the
+// format
+func LoadRules(path string) (*Rules, error) {
+	rules := &Rules{path: path}
+	err := rules.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func (r *Rules) Check(body string) []Match {
+	r.mux.RLock()
+	filter := r.filter
+	r.mux.RUnlock()
+
+	return filter.Check(body)
+}
+
+// Reload reads the rules file again. When it can not be read or parsed the
+// rules in use are kept.
+func (r *Rules) Reload() error {
+	file, err := os.Open(r.path)
+	if err != nil {
+		return fmt.Errorf("unable to open moderation rules: %s", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("unable to open moderation rules: %s", err)
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	// a broken file is not retried until it changes again
+	r.modTime = info.ModTime()
+	r.size = info.Size()
+
+	chain, err := ParseRules(file)
+	if err != nil {
+		return fmt.Errorf("invalid moderation rules in %s: %s", r.path, err)
+	}
+
+	r.filter = chain
+	return nil
+}
+
+// changed reports whether the rules file differs from what was last loaded
+func (r *Rules) changed() (bool, error) {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return false, err
+	}
+
+	r.mux.RLock()
+	defer r.mux.RUnlock()
+
+	return !info.ModTime().Equal(r.modTime) || info.Size() != r.size, nil
+}
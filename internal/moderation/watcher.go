@@ -0,0 +1,78 @@
+package moderation
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Watcher reloads Rules whenever their file changes
+type Watcher struct {
+	rules    *Rules
+	interval time.Duration
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// Watch checks the file of rules for changes every interval until Stop is
+// called
+func Watch(rules *Rules, interval time.Duration) (*Watcher, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("moderation reload interval must be more than 0, got %s", interval)
+	}
+
+	w := &Watcher{
+		rules:    rules,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go w.run()
+
+	return w, nil
+}
+
+// Stop waits for a reload in progress to finish and stops the watcher
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+}
+
+func (w *Watcher) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.check()
+		}
+	}
+}
+
+func (w *Watcher) check() {
+	changed, err := w.rules.changed()
+	if err != nil {
+		log.Printf("unable to check moderation rules: %s", err)
+		return
+	}
+	if !changed {
+		return
+	}
+
+	err = w.rules.Reload()
+	if err != nil {
+		log.Printf("unable to reload moderation rules, keeping the old ones: %s", err)
+		return
+	}
+	log.Printf("Reloaded moderation rules from %s", w.rules.path)
+}
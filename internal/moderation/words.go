@@ -0,0 +1,156 @@
+package moderation
+
+import (
+	"strings"
+	"unicode"
+)
+
+// folds maps accented letters, look-alikes from other scripts and the usual
+// digit and symbol stand-ins to the plain letter they pass for
+var folds = map[rune]rune{}
+
+func init() {
+	for plain, lookalikes := range map[rune]string{
+		'a': "àáâãäåāăąаα4@",
+		'b': "в",
+		'c': "çćĉċčс",
+		'd': "ďđ",
+		'e': "èéêëēĕėęěеε3",
+		'g': "ĝğġģ",
+		'h': "ĥħн",
+		'i': "ìíîïĩīĭįıіι1",
+		'j': "ĵј",
+		'k': "ķкκ",
+		'l': "ĺļľŀł",
+		'm': "м",
+		'n': "ñńņňŉ",
+		'o': "òóôõöøōŏőоο0",
+		'p': "рρ",
+		'r': "ŕŗř",
+		's': "śŝşšșѕ5$",
+		't': "ţťŧțт7",
+		'u': "ùúûüũūŭůűų",
+		'w': "ŵ",
+		'x': "хχ",
+		'y': "ýÿŷу",
+		'z': "źżž",
+	} {
+		for _, r := range lookalikes {
+			folds[r] = plain
+		}
+	}
+}
+
+// Normalize returns the form words are compared in: lowercased, with
+// accents, zero width characters and look-alikes folded away, so
+// "KÉRFÚFFLE" and "k3rfuffl3" both read "kerfuffle"
+func Normalize(word string) string {
+	var normalized strings.Builder
+	for _, r := range word {
+		// combining accents and invisible formatting characters
+		if unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Cf, r) {
+			continue
+		}
+		// fullwidth forms of ASCII
+		if r >= 0xFF01 && r <= 0xFF5E {
+			r -= 0xFEE0
+		}
+
+		r = unicode.ToLower(r)
+		if plain, ok := folds[r]; ok {
+			r = plain
+		}
+		normalized.WriteRune(r)
+	}
+
+	return normalized.String()
+}
+
+// WordList catches listed words wherever they stand in a body, whatever the
+// case, accents or punctuation around them, and words spelled out with
+// punctuation in between like "k.e.r.f.u.f.f.l.e"
+type WordList struct {
+	words map[string]Action
+}
+
+// NewWordList catches each of the words with its action
+func NewWordList(words map[string]Action) *WordList {
+	list := &WordList{words: map[string]Action{}}
+	for word, action := range words {
+		list.words[Normalize(word)] = action
+	}
+
+	return list
+}
+
+func (l *WordList) Check(body string) []Match {
+	matches := []Match{}
+	for _, chunk := range wordChunks(body) {
+		joined := ""
+		for _, word := range chunk {
+			// a leading @ makes a mention rather than an a
+			if body[word.start] == '@' && word.end-word.start > 1 {
+				word.start++
+			}
+			normalized := Normalize(body[word.start:word.end])
+			if action, ok := l.words[normalized]; ok {
+				matches = append(matches, Match{Rule: normalized, Action: action, Start: word.start, End: word.end})
+			}
+			joined += normalized
+		}
+
+		if len(chunk) < 2 {
+			continue
+		}
+		if action, ok := l.words[joined]; ok {
+			matches = append(matches, Match{Rule: joined, Action: action, Start: chunk[0].start, End: chunk[len(chunk)-1].end})
+		}
+	}
+
+	return matches
+}
+
+type span struct {
+	start int
+	end   int
+}
+
+// wordChunks splits body at white space into chunks, and each chunk into
+// its words
+func wordChunks(body string) [][]span {
+	chunks := [][]span{}
+	chunk := []span{}
+	start := -1
+	for i, r := range body {
+		if isWordRune(r) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+
+		if start >= 0 {
+			chunk = append(chunk, span{start: start, end: i})
+			start = -1
+		}
+		if unicode.IsSpace(r) && len(chunk) > 0 {
+			chunks = append(chunks, chunk)
+			chunk = []span{}
+		}
+	}
+	if start >= 0 {
+		chunk = append(chunk, span{start: start, end: len(body)})
+	}
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks
+}
+
+// isWordRune reports whether r can be part of a word, including the
+// symbols that stand in for letters
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || unicode.Is(unicode.Cf, r) ||
+		r == '@' || r == '$'
+}
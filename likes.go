@@ -0,0 +1,117 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/DuganChandler/goserver/internal/auth"
+	"github.com/DuganChandler/goserver/internal/database"
+)
+
+// likeChirpHandler likes a chirp for the current user, liking it again is a
+// no-op
+func (cfg *apiConfig) likeChirpHandler(w http.ResponseWriter, req *http.Request) {
+	cfg.setChirpLike(w, req, cfg.DB.LikeChirp)
+}
+
+// unlikeChirpHandler removes the current user's like, if any
+func (cfg *apiConfig) unlikeChirpHandler(w http.ResponseWriter, req *http.Request) {
+	cfg.setChirpLike(w, req, cfg.DB.UnlikeChirp)
+}
+
+func (cfg *apiConfig) setChirpLike(w http.ResponseWriter, req *http.Request, set func(chirpID, userID int) (bool, error)) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "no token provided")
+		return
+	}
+
+	subject, err := auth.VerifyJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "unable to verify jwt token")
+		return
+	}
+
+	userID, err := strconv.Atoi(subject)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to turn subject to user id")
+		return
+	}
+
+	chirpID, err := strconv.Atoi(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	_, err = set(chirpID, userID)
+	if errors.Is(err, database.ErrChirpNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getChirpLikesHandler lists who liked a chirp, most recent first
+func (cfg *apiConfig) getChirpLikesHandler(w http.ResponseWriter, req *http.Request) {
+	chirpID, err := strconv.Atoi(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	limit, offset, ok := offsetPage(w, req)
+	if !ok {
+		return
+	}
+
+	likes, err := fetchOffsetPage(w, req, offset, limit, func(offset, limit int) ([]database.Like, error) {
+		return cfg.DB.GetChirpLikes(chirpID, offset, limit)
+	})
+	if errors.Is(err, database.ErrChirpNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responseWithJSON(w, http.StatusOK, likes)
+}
+
+// getUserLikesHandler lists the chirps a user liked, most recently liked
+// first
+func (cfg *apiConfig) getUserLikesHandler(w http.ResponseWriter, req *http.Request) {
+	userID, err := strconv.Atoi(req.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if _, err := cfg.DB.GetUserByID(userID); err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	limit, offset, ok := offsetPage(w, req)
+	if !ok {
+		return
+	}
+
+	chirps, err := fetchOffsetPage(w, req, offset, limit, func(offset, limit int) ([]database.Chirp, error) {
+		return cfg.DB.GetLikedChirps(userID, offset, limit)
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responseWithJSON(w, http.StatusOK, chirps)
+}
@@ -1,18 +1,32 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/DuganChandler/goserver/internal/database"
+	"github.com/DuganChandler/goserver/internal/moderation"
 	"github.com/joho/godotenv"
 )
 
 type apiConfig struct {
-	fileserverHits int
-	DB             *database.DB
-	JWTSecret      string
+	fileserverHits  int
+	DB              database.Store
+	Janitor         *database.Janitor
+	JWTSecret       string
+	AdminAPIKey     string
+	BackupDir       string
+	BackupRetention int
+	ChirpRetention  time.Duration
+	Moderator       moderation.Filter
 }
 
 func main() {
@@ -20,17 +34,64 @@ func main() {
 	const port = "8080"
 	godotenv.Load()
 
-	db, err := database.NewDB("database.json")
+	if len(os.Args) > 1 {
+		err := runCommand(os.Args[1], os.Args[2:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	db, err := openDB()
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	jwtSecret := os.Getenv("JWT_SECRET")
 
+	backupDir, backupRetention, err := backupConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// TOKEN_SWEEP_INTERVAL_MS is the name from before the janitor swept
+	// more than refresh tokens, it still works when the new one is unset
+	sweepInterval, err := envMillis("TOKEN_SWEEP_INTERVAL_MS", 10*time.Minute)
+	if err != nil {
+		log.Fatal(err)
+	}
+	janitorInterval, err := envMillis("JANITOR_INTERVAL_MS", sweepInterval)
+	if err != nil {
+		log.Fatal(err)
+	}
+	chirpRetention, err := envMillis("CHIRP_RETENTION_MS", 30*24*time.Hour)
+	if err != nil {
+		log.Fatal(err)
+	}
+	eventRetention, err := envMillis("EVENT_RETENTION_MS", 7*24*time.Hour)
+	if err != nil {
+		log.Fatal(err)
+	}
+	janitor, err := database.StartJanitor(db, janitorInterval, chirpRetention, eventRetention)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	moderator, watcher, err := moderationConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	apiCfg := &apiConfig{
-		fileserverHits: 0,
-		DB:             db,
-		JWTSecret:      jwtSecret,
+		fileserverHits:  0,
+		DB:              db,
+		Janitor:         janitor,
+		JWTSecret:       jwtSecret,
+		AdminAPIKey:     os.Getenv("ADMIN_API_KEY"),
+		BackupDir:       backupDir,
+		BackupRetention: backupRetention,
+		ChirpRetention:  chirpRetention,
+		Moderator:       moderator,
 	}
 
 	mux := http.NewServeMux()
@@ -44,25 +105,220 @@ func main() {
 	mux.HandleFunc("POST /api/revoke", apiCfg.revokeTokenHandler)
 
 	mux.HandleFunc("GET /api/chirps", apiCfg.getChirpsHandler)
+	mux.HandleFunc("GET /api/chirps/search", apiCfg.searchChirpsHandler)
 	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.getChirpByIDHandler)
 	mux.HandleFunc("POST /api/chirps", apiCfg.createChirpsHandler)
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", apiCfg.editChirpHandler)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", apiCfg.getChirpRevisionsHandler)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/replies", apiCfg.getChirpRepliesHandler)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", apiCfg.getThreadHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/rechirp", apiCfg.rechirpHandler)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/rechirp", apiCfg.unrechirpHandler)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/quotes", apiCfg.getChirpQuotesHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/likes", apiCfg.likeChirpHandler)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/likes", apiCfg.unlikeChirpHandler)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/likes", apiCfg.getChirpLikesHandler)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.deleteChirpByIDHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/restore", apiCfg.restoreChirpByIDHandler)
+
+	mux.HandleFunc("GET /api/timeline", apiCfg.getTimelineHandler)
+	mux.HandleFunc("GET /api/hashtags/trending", apiCfg.getTrendingHashtagsHandler)
+	mux.HandleFunc("GET /api/hashtags/{tag}/chirps", apiCfg.getHashtagChirpsHandler)
 
 	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.upgradeUser)
 
 	mux.HandleFunc("POST /api/users", apiCfg.createUsersHandler)
+	mux.HandleFunc("GET /api/users/me", apiCfg.getCurrentUserHandler)
+	mux.HandleFunc("GET /api/users/{handle}", apiCfg.getUserProfileHandler)
+	mux.HandleFunc("GET /api/users/me/mentions", apiCfg.getMentionsHandler)
+	mux.HandleFunc("GET /api/users/{userID}/likes", apiCfg.getUserLikesHandler)
+	mux.HandleFunc("POST /api/users/{userID}/follow", apiCfg.followHandler)
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", apiCfg.unfollowHandler)
+	mux.HandleFunc("GET /api/users/{userID}/followers", apiCfg.getFollowersHandler)
+	mux.HandleFunc("GET /api/users/{userID}/following", apiCfg.getFollowingHandler)
 	mux.HandleFunc("PUT /api/users", apiCfg.updateUsersLoginHandler)
 
 	mux.HandleFunc("POST /api/login", apiCfg.loginUsersHadler)
 
 	// ADMIN
 	mux.HandleFunc("GET /admin/metrics", apiCfg.getHits)
+	mux.HandleFunc("GET /admin/events", apiCfg.getEventsHandler)
+	mux.HandleFunc("GET /admin/backups", apiCfg.listBackupsHandler)
+	mux.HandleFunc("POST /admin/backups", apiCfg.createBackupHandler)
+	mux.HandleFunc("POST /admin/backups/{backupName}/restore", apiCfg.restoreBackupHandler)
 
 	srv := &http.Server{
 		Addr:    ":" + port,
 		Handler: mux,
 	}
 
-	log.Printf("Serving files from %s on port: %s\n", filePathRoot, port)
-	log.Fatal(srv.ListenAndServe())
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		log.Printf("Serving files from %s on port: %s\n", filePathRoot, port)
+		err := srv.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	err = srv.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Printf("unable to shut down server cleanly: %s", err)
+	}
+	janitor.Stop()
+	if watcher != nil {
+		watcher.Stop()
+	}
+
+	err = db.Close()
+	if err != nil {
+		log.Fatalf("unable to close db: %s", err)
+	}
+}
+
+// dbLocation returns the driver and path set by DB_DRIVER and DB_PATH
+func dbLocation() (string, string) {
+	driver := os.Getenv("DB_DRIVER")
+	path := os.Getenv("DB_PATH")
+	if path == "" {
+		path = "database.json"
+		if driver == "sqlite" {
+			path = "database.db"
+		}
+	}
+	return driver, path
+}
+
+// openDB opens the store configured through the DB_* environment variables
+func openDB() (database.Store, error) {
+	driver, path := dbLocation()
+
+	opts, err := dbOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	return database.Open(driver, path, opts)
+}
+
+// dbOptions builds the store options from DB_PERSIST,
+// DB_PERSIST_INTERVAL_MS and the encryption key settings
+func dbOptions() (database.Options, error) {
+	persist, err := database.ParsePersistPolicy(os.Getenv("DB_PERSIST"))
+	if err != nil {
+		return database.Options{}, err
+	}
+	opts := database.Options{
+		Persist: persist,
+	}
+
+	opts.PersistInterval, err = envMillis("DB_PERSIST_INTERVAL_MS", 0)
+	if err != nil {
+		return database.Options{}, err
+	}
+
+	opts.EncryptionKey, err = encryptionKey()
+	if err != nil {
+		return database.Options{}, err
+	}
+
+	return opts, nil
+}
+
+// encryptionKey returns the key from DB_ENCRYPTION_KEY, or read from the
+// file named by DB_ENCRYPTION_KEY_FILE, or nil when neither is set
+func encryptionKey() ([]byte, error) {
+	if key := os.Getenv("DB_ENCRYPTION_KEY"); key != "" {
+		return database.ParseEncryptionKey(key)
+	}
+
+	keyFile := os.Getenv("DB_ENCRYPTION_KEY_FILE")
+	if keyFile == "" {
+		return nil, nil
+	}
+
+	return readEncryptionKeyFile(keyFile)
+}
+
+func readEncryptionKeyFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read encryption key file: %s", err)
+	}
+
+	return database.ParseEncryptionKey(string(data))
+}
+
+// envMillis reads a positive duration given in milliseconds from the
+// environment
+func envMillis(name string, fallback time.Duration) (time.Duration, error) {
+	val := os.Getenv(name)
+	if val == "" {
+		return fallback, nil
+	}
+
+	ms, err := strconv.Atoi(val)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %s", name, err)
+	}
+	if ms <= 0 {
+		return 0, fmt.Errorf("invalid %s: must be more than 0", name)
+	}
+
+	return time.Duration(ms) * time.Millisecond, nil
+}
+
+// moderationConfig loads the moderation rules from MODERATION_RULES_PATH
+// and watches the file for changes every MODERATION_RELOAD_INTERVAL_MS.
+// Without a rules file the default word list is masked and there is
+// nothing to watch.
+func moderationConfig() (moderation.Filter, *moderation.Watcher, error) {
+	path := os.Getenv("MODERATION_RULES_PATH")
+	if path == "" {
+		return moderation.Default(), nil, nil
+	}
+
+	rules, err := moderation.LoadRules(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	interval, err := envMillis("MODERATION_RELOAD_INTERVAL_MS", 30*time.Second)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	watcher, err := moderation.Watch(rules, interval)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rules, watcher, nil
+}
+
+// backupConfig returns the backup dir and how many backups to keep, set by
+// BACKUP_DIR and BACKUP_RETENTION
+func backupConfig() (string, int, error) {
+	dir := os.Getenv("BACKUP_DIR")
+	if dir == "" {
+		dir = "backups"
+	}
+
+	retention := 7
+	if keep := os.Getenv("BACKUP_RETENTION"); keep != "" {
+		n, err := strconv.Atoi(keep)
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid BACKUP_RETENTION: %s", err)
+		}
+		retention = n
+	}
+
+	return dir, retention, nil
 }
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/DuganChandler/goserver/internal/auth"
+)
+
+// getMentionsHandler lists the chirps mentioning the current user, newest
+// first unless sort_by or sort say otherwise, taking the same parameters
+// as GET /api/chirps
+func (cfg *apiConfig) getMentionsHandler(w http.ResponseWriter, req *http.Request) {
+	token, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "no token provided")
+		return
+	}
+
+	subject, err := auth.VerifyJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "unable to verify jwt token")
+		return
+	}
+
+	userID, err := strconv.Atoi(subject)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to turn subject to user id")
+		return
+	}
+
+	query, err := parseChirpQuery(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query = newestFirstByDefault(req, query)
+	query.Mentioning = userID
+
+	cfg.respondWithChirpPage(w, req, query)
+}
@@ -6,6 +6,8 @@ import (
 )
 
 func (cfg *apiConfig) getHits(w http.ResponseWriter, req *http.Request) {
+	janitorStats := cfg.Janitor.Stats()
+
 	w.Header().Add("Content-Type", "text/html")
 	w.WriteHeader(http.StatusOK)
 	responseString := fmt.Sprintf(`<html>
@@ -13,9 +15,12 @@ func (cfg *apiConfig) getHits(w http.ResponseWriter, req *http.Request) {
 <body>
     <h1>Welcome, Chirpy Admin</h1>
     <p>Chirpy has been visited %d times!</p>
+    <p>Stale refresh tokens swept: %d over %d runs</p>
+    <p>Deleted chirps purged: %d</p>
+    <p>Old events purged: %d</p>
 </body>
 
-</html>`, cfg.fileserverHits)
+</html>`, cfg.fileserverHits, janitorStats.RefreshTokensSwept, janitorStats.Runs, janitorStats.ChirpsPurged, janitorStats.EventsPurged)
 	w.Write([]byte(responseString))
 }
 
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/DuganChandler/goserver/internal/moderation"
+)
+
+// moderateChirp runs body past the moderation rules. When a rule rejects it,
+// or masking made it longer than a chirp may be, it responds with 400 and
+// returns false.
+func (cfg *apiConfig) moderateChirp(w http.ResponseWriter, body string) (moderation.Result, bool) {
+	result := moderation.Moderate(cfg.Moderator, body)
+	if len(result.Rejected) > 0 {
+		respondWithError(w, http.StatusBadRequest, "Chirp breaks the content rules")
+		return moderation.Result{}, false
+	}
+	if len(result.Body) > maxChirpLength {
+		respondWithError(w, http.StatusBadRequest, "Chirp is too long once masked")
+		return moderation.Result{}, false
+	}
+
+	return result, true
+}
+
+// flagForReview queues chirpID for moderators when result flagged it. The
+// chirp is already saved, so failing to flag it only gets logged.
+func (cfg *apiConfig) flagForReview(chirpID int, result moderation.Result) {
+	if len(result.Flagged) == 0 {
+		return
+	}
+
+	err := cfg.DB.FlagChirp(chirpID, moderation.RuleNames(result.Flagged))
+	if err != nil {
+		log.Printf("unable to flag chirp %d for review: %s", chirpID, err)
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/DuganChandler/goserver/internal/moderation"
+)
+
+func TestMaskingPastTheLengthLimit(t *testing.T) {
+	cfg, mux, token := testServer(t)
+	cfg.Moderator = moderation.RegexRule{Pattern: regexp.MustCompile(`x`), Action: moderation.ActionMask}
+	bearer := http.Header{"Authorization": {"Bearer " + token}}
+
+	// 140 bytes that mask to 560
+	body := strings.Repeat("x", maxChirpLength)
+	rec := serve(mux, "POST", "/api/chirps", `{"body": "`+body+`"}`, bearer)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("got %d creating a chirp masked past the limit, want 400", rec.Code)
+	}
+	_, err := cfg.DB.GetChirpByID(1)
+	if err == nil {
+		t.Error("chirp masked past the limit was saved")
+	}
+
+	rec = serve(mux, "POST", "/api/chirps", `{"body": "a fine chirp"}`, bearer)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("got %d creating a chirp: %s", rec.Code, rec.Body)
+	}
+	rec = serve(mux, "PUT", "/api/chirps/1", `{"body": "`+body+`"}`, bearer)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("got %d editing a chirp masked past the limit, want 400", rec.Code)
+	}
+	chirp, err := cfg.DB.GetChirpByID(1)
+	if err != nil || chirp.Body != "a fine chirp" {
+		t.Errorf("chirp was edited to %+v, %v", chirp, err)
+	}
+
+	rec = serve(mux, "POST", "/api/chirps", `{"body": "xx marks the spot"}`, bearer)
+	if rec.Code != http.StatusCreated || !strings.Contains(rec.Body.String(), `"********`) {
+		t.Errorf("got %d masking a short chirp: %s", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/DuganChandler/goserver/internal/database"
+)
+
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+// chirpCursor is where a chirps listing left off. It is handed to clients
+// base64 encoded and is only valid for the query that produced it, which
+// Query fingerprints.
+type chirpCursor struct {
+	After     int    `json:"after"`
+	CreatedAt int64  `json:"created_at,omitempty"`
+	Query     string `json:"query"`
+}
+
+func newChirpCursor(chirp database.Chirp, req *http.Request) string {
+	return encodeCursor(chirpCursor{
+		After:     chirp.Id,
+		CreatedAt: chirp.CreatedAt.UnixNano(),
+		Query:     queryFingerprint(req),
+	})
+}
+
+// decodeChirpCursor returns the position a cursor continues from
+func decodeChirpCursor(s string, req *http.Request) (database.ChirpPosition, error) {
+	cursor := chirpCursor{}
+	err := decodeCursor(s, &cursor)
+	if err != nil || cursor.After < 1 {
+		return database.ChirpPosition{}, fmt.Errorf("invalid cursor")
+	}
+	if cursor.Query != queryFingerprint(req) {
+		return database.ChirpPosition{}, fmt.Errorf("cursor belongs to a different query")
+	}
+
+	return database.ChirpPosition{
+		ID:        cursor.After,
+		CreatedAt: time.Unix(0, cursor.CreatedAt),
+	}, nil
+}
+
+// offsetCursor pages through results that have no stable key to continue
+// from, like search results ordered by relevance
+type offsetCursor struct {
+	Offset int    `json:"offset"`
+	Query  string `json:"query"`
+}
+
+func newOffsetCursor(offset int, req *http.Request) string {
+	return encodeCursor(offsetCursor{
+		Offset: offset,
+		Query:  queryFingerprint(req),
+	})
+}
+
+// decodeOffsetCursor returns the offset a cursor continues from
+func decodeOffsetCursor(s string, req *http.Request) (int, error) {
+	cursor := offsetCursor{}
+	err := decodeCursor(s, &cursor)
+	if err != nil || cursor.Offset < 1 {
+		return 0, fmt.Errorf("invalid cursor")
+	}
+	if cursor.Query != queryFingerprint(req) {
+		return 0, fmt.Errorf("cursor belongs to a different query")
+	}
+
+	return cursor.Offset, nil
+}
+
+// offsetPage reads the limit and offset cursor of a request, it responds
+// with 400 and returns false when either is invalid
+func offsetPage(w http.ResponseWriter, req *http.Request) (int, int, bool) {
+	limit, err := pageLimit(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return 0, 0, false
+	}
+
+	offset := 0
+	if val := req.URL.Query().Get("cursor"); val != "" {
+		offset, err = decodeOffsetCursor(val, req)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return 0, 0, false
+		}
+	}
+
+	return limit, offset, true
+}
+
+func encodeCursor(cursor any) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string, cursor any) error {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, cursor)
+}
+
+// queryFingerprint identifies the listing a request is for: its path and
+// everything in the query string but the paging parameters
+func queryFingerprint(req *http.Request) string {
+	query := url.Values{}
+	for key, vals := range req.URL.Query() {
+		if key != "cursor" && key != "limit" {
+			query[key] = vals
+		}
+	}
+
+	sum := sha256.Sum256([]byte(req.URL.Path + "?" + query.Encode()))
+	return hex.EncodeToString(sum[:8])
+}
+
+// pageLimit reads the limit query parameter
+func pageLimit(req *http.Request) (int, error) {
+	val := req.URL.Query().Get("limit")
+	if val == "" {
+		return defaultPageLimit, nil
+	}
+
+	limit, err := strconv.Atoi(val)
+	if err != nil || limit < 1 || limit > maxPageLimit {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+	}
+
+	return limit, nil
+}
+
+// fetchPage fetches up to limit items, asking fetch for one more to learn
+// whether there is a next page. When there is, the Link header points at
+// it through the cursor made from the last item returned.
+func fetchPage[T any](w http.ResponseWriter, req *http.Request, limit int, fetch func(limit int) ([]T, error), cursor func(last T) string) ([]T, error) {
+	page, err := fetch(limit + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(page) > limit {
+		page = page[:limit]
+		setNextLink(w, req, cursor(page[limit-1]))
+	}
+
+	return page, nil
+}
+
+// fetchOffsetPage is fetchPage for listings paged by offsetCursor
+func fetchOffsetPage[T any](w http.ResponseWriter, req *http.Request, offset, limit int, fetch func(offset, limit int) ([]T, error)) ([]T, error) {
+	return fetchPage(w, req, limit, func(limit int) ([]T, error) {
+		return fetch(offset, limit)
+	}, func(T) string {
+		return newOffsetCursor(offset+limit, req)
+	})
+}
+
+// setNextLink points the Link header at the same request continued from
+// cursor
+func setNextLink(w http.ResponseWriter, req *http.Request, cursor string) {
+	query := req.URL.Query()
+	query.Set("cursor", cursor)
+
+	w.Header().Set("Link", fmt.Sprintf(`<%s?%s>; rel="next"`, req.URL.Path, query.Encode()))
+}
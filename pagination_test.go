@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/DuganChandler/goserver/internal/database"
+)
+
+var nextLink = regexp.MustCompile(`^<(.+)>; rel="next"$`)
+
+// pageThrough follows the Link headers from path and returns the ids of
+// every page
+func pageThrough(t *testing.T, mux *http.ServeMux, path string) [][]int {
+	t.Helper()
+
+	pages := [][]int{}
+	for path != "" {
+		rec := serve(mux, "GET", path, "", nil)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("got %d for %s: %s", rec.Code, path, rec.Body)
+		}
+		chirps := []database.Chirp{}
+		err := json.Unmarshal(rec.Body.Bytes(), &chirps)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []int{}
+		for _, chirp := range chirps {
+			ids = append(ids, chirp.Id)
+		}
+		pages = append(pages, ids)
+
+		path = ""
+		if match := nextLink.FindStringSubmatch(rec.Header().Get("Link")); match != nil {
+			path = match[1]
+		}
+	}
+	return pages
+}
+
+func TestPagesFollowNextLink(t *testing.T) {
+	_, mux, token := testServer(t)
+	bearer := http.Header{"Authorization": {"Bearer " + token}}
+	for _, body := range []string{"paging one", "paging two", "paging three"} {
+		rec := serve(mux, "POST", "/api/chirps", `{"body": "`+body+`"}`, bearer)
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("got %d creating a chirp: %s", rec.Code, rec.Body)
+		}
+	}
+
+	// search pages by offset and orders by relevance, so only the page
+	// sizes are compared and every chirp must turn up once
+	for path, want := range map[string]string{
+		"/api/chirps?sort=asc&limit=2":        "[2 1]",
+		"/api/chirps?sort=asc&limit=3":        "[3]",
+		"/api/chirps/search?q=paging&limit=2": "[2 1]",
+	} {
+		sizes := []int{}
+		seen := map[int]bool{}
+		for _, page := range pageThrough(t, mux, path) {
+			sizes = append(sizes, len(page))
+			for _, id := range page {
+				seen[id] = true
+			}
+		}
+		if got := fmt.Sprint(sizes); got != want || len(seen) != 3 {
+			t.Errorf("%s paged as %s over %d chirps, want %s over 3", path, got, len(seen), want)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"unicode/utf8"
+
+	"github.com/DuganChandler/goserver/internal/database"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+	maxAvatarURLLength   = 2048
+)
+
+// publicProfile is what anyone can see of a user, it leaves out the email
+// address, password hash and tokens
+type publicProfile struct {
+	ID          int    `json:"id"`
+	Handle      string `json:"handle"`
+	DisplayName string `json:"display_name"`
+	Bio         string `json:"bio"`
+	AvatarURL   string `json:"avatar_url"`
+	IsChirpyRed bool   `json:"is_chirpy_red"`
+}
+
+// getUserProfileHandler looks up the public profile of a user by handle
+func (cfg *apiConfig) getUserProfileHandler(w http.ResponseWriter, req *http.Request) {
+	handle, err := database.NormalizeHandle(req.PathValue("handle"))
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "user does not exist")
+		return
+	}
+
+	user, err := cfg.DB.GetUserByHandle(handle)
+	if errors.Is(err, database.ErrUserNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to get user")
+		return
+	}
+
+	if notModified(w, req, user.Version) {
+		return
+	}
+
+	responseWithJSON(w, http.StatusOK, publicProfile{
+		ID:          user.Id,
+		Handle:      user.Handle,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarURL:   user.AvatarURL,
+		IsChirpyRed: user.IsChirpyRed,
+	})
+}
+
+// validateProfile checks the free form profile fields of user
+func validateProfile(user database.User) error {
+	if utf8.RuneCountInString(user.DisplayName) > maxDisplayNameLength {
+		return fmt.Errorf("display_name must be at most %d characters", maxDisplayNameLength)
+	}
+	if utf8.RuneCountInString(user.Bio) > maxBioLength {
+		return fmt.Errorf("bio must be at most %d characters", maxBioLength)
+	}
+
+	if user.AvatarURL == "" {
+		return nil
+	}
+	if len(user.AvatarURL) > maxAvatarURLLength {
+		return fmt.Errorf("avatar_url must be at most %d characters", maxAvatarURLLength)
+	}
+	avatar, err := url.Parse(user.AvatarURL)
+	if err != nil || avatar.Host == "" || (avatar.Scheme != "http" && avatar.Scheme != "https") {
+		return errors.New("avatar_url must be an http or https URL")
+	}
+
+	return nil
+}
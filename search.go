@@ -0,0 +1,33 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/DuganChandler/goserver/internal/database"
+)
+
+// searchChirpsHandler finds chirps matching q, best match first. q takes
+// space separated terms, "quoted phrases" and prefix* terms, all of which
+// must match. When there are more, the Link header points at the next page.
+func (cfg *apiConfig) searchChirpsHandler(w http.ResponseWriter, req *http.Request) {
+	query, err := database.ParseSearchQuery(req.URL.Query().Get("q"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	limit, offset, ok := offsetPage(w, req)
+	if !ok {
+		return
+	}
+
+	chirps, err := fetchOffsetPage(w, req, offset, limit, func(offset, limit int) ([]database.Chirp, error) {
+		return cfg.DB.SearchChirps(query, offset, limit)
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	responseWithJSON(w, http.StatusOK, chirps)
+}
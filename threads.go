@@ -0,0 +1,83 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/DuganChandler/goserver/internal/database"
+)
+
+// maxThreadSize caps how many chirps GET /api/chirps/{chirpID}/thread
+// returns, replies past it are left out
+const maxThreadSize = 1000
+
+// threadNode is a chirp and the replies to it. Deleted chirps keep their
+// place in the tree so their replies stay reachable, but only their id.
+type threadNode struct {
+	database.Chirp
+	Deleted bool          `json:"deleted,omitempty"`
+	Replies []*threadNode `json:"replies"`
+}
+
+// getChirpRepliesHandler lists the direct replies to a chirp a page at a
+// time, taking the same parameters as GET /api/chirps
+func (cfg *apiConfig) getChirpRepliesHandler(w http.ResponseWriter, req *http.Request) {
+	chirpID, err := strconv.Atoi(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	_, err = cfg.DB.GetChirpByID(chirpID)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	query, err := parseChirpQuery(req)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	query.InReplyTo = chirpID
+
+	cfg.respondWithChirpPage(w, req, query)
+}
+
+// getThreadHandler responds with the whole conversation a chirp is part of,
+// as a tree rooted at the chirp that started it
+func (cfg *apiConfig) getThreadHandler(w http.ResponseWriter, req *http.Request) {
+	chirpID, err := strconv.Atoi(req.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	chirps, err := cfg.DB.GetThread(chirpID, maxThreadSize)
+	if errors.Is(err, database.ErrChirpNotFound) {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// parents always come before their replies
+	nodes := map[int]*threadNode{}
+	for _, chirp := range chirps {
+		node := &threadNode{Chirp: chirp, Replies: []*threadNode{}}
+		if chirp.DeletedAt != nil {
+			node.Chirp = database.Chirp{Id: chirp.Id, InReplyTo: chirp.InReplyTo}
+			node.Deleted = true
+		}
+		nodes[chirp.Id] = node
+
+		if parent, ok := nodes[chirp.InReplyTo]; ok {
+			parent.Replies = append(parent.Replies, node)
+		}
+	}
+
+	responseWithJSON(w, http.StatusOK, nodes[chirps[0].Id])
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -16,6 +17,7 @@ func (cfg *apiConfig) createUsersHandler(w http.ResponseWriter, req *http.Reques
 	type parameters struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		Handle   string `json:"handle"`
 	}
 
 	decoder := json.NewDecoder(req.Body)
@@ -26,28 +28,100 @@ func (cfg *apiConfig) createUsersHandler(w http.ResponseWriter, req *http.Reques
 		return
 	}
 
+	// every new user gets a handle to be mentioned and found by, only
+	// users from before handles existed go without
+	if params.Handle == "" {
+		respondWithError(w, http.StatusBadRequest, "a handle is required")
+		return
+	}
+	params.Handle, err = database.NormalizeHandle(params.Handle)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	hashedPassword, err := auth.HashPassword(params.Password)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, err.Error())
 	}
 
-	user, err := cfg.DB.CreateUsers(params.Email, string(hashedPassword))
+	user, err := cfg.DB.CreateUsers(database.User{
+		Email:    params.Email,
+		Handle:   params.Handle,
+		Password: string(hashedPassword),
+	})
+	if errors.Is(err, database.ErrHandleTaken) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "error creating user")
 		return
 	}
 
+	w.Header().Set("ETag", etag(user.Version))
 	responseWithJSON(w, http.StatusCreated, database.User{
 		Id:          user.Id,
 		Email:       user.Email,
+		Handle:      user.Handle,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarURL:   user.AvatarURL,
+		IsChirpyRed: user.IsChirpyRed,
+		Version:     user.Version,
+	})
+}
+
+func (cfg *apiConfig) getCurrentUserHandler(w http.ResponseWriter, req *http.Request) {
+	tokenString, err := auth.GetBearerToken(req.Header)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "could not find JWT")
+		return
+	}
+
+	subject, err := auth.VerifyJWT(tokenString, cfg.JWTSecret)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, fmt.Sprintf("unable to verify jwt: %s", err))
+		return
+	}
+
+	userIDInt, err := strconv.Atoi(subject)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "could not parse user ID")
+		return
+	}
+
+	user, err := cfg.DB.GetUserByID(userIDInt)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	if notModified(w, req, user.Version) {
+		return
+	}
+
+	responseWithJSON(w, http.StatusOK, database.User{
+		Id:          user.Id,
+		Email:       user.Email,
+		Handle:      user.Handle,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarURL:   user.AvatarURL,
 		IsChirpyRed: user.IsChirpyRed,
+		Version:     user.Version,
 	})
 }
 
 func (cfg *apiConfig) updateUsersLoginHandler(w http.ResponseWriter, req *http.Request) {
+	// fields left out of the body keep their current value
 	type parameters struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email       *string `json:"email"`
+		Password    *string `json:"password"`
+		Handle      *string `json:"handle"`
+		DisplayName *string `json:"display_name"`
+		Bio         *string `json:"bio"`
+		AvatarURL   *string `json:"avatar_url"`
 	}
 
 	tokenString, err := auth.GetBearerToken(req.Header)
@@ -70,29 +144,93 @@ func (cfg *apiConfig) updateUsersLoginHandler(w http.ResponseWriter, req *http.R
 		return
 	}
 
-	password, err := bcrypt.GenerateFromPassword([]byte(params.Password), 14)
+	userIDInt, err := strconv.Atoi(subject)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "unable to hash password")
+		respondWithError(w, http.StatusInternalServerError, "could not parse user ID")
 		return
 	}
 
-	userIDInt, err := strconv.Atoi(subject)
+	current, err := cfg.DB.GetUserByID(userIDInt)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "could not parse user ID")
+		respondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	updated := current
+	if params.Email != nil {
+		updated.Email = *params.Email
+	}
+	if params.Handle != nil {
+		updated.Handle, err = database.NormalizeHandle(*params.Handle)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if params.DisplayName != nil {
+		updated.DisplayName = *params.DisplayName
+	}
+	if params.Bio != nil {
+		updated.Bio = *params.Bio
+	}
+	if params.AvatarURL != nil {
+		updated.AvatarURL = *params.AvatarURL
+	}
+	err = validateProfile(updated)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	user, err := cfg.DB.UpdateUserLogin(params.Email, string(password), userIDInt)
+	if params.Password != nil {
+		password, err := bcrypt.GenerateFromPassword([]byte(*params.Password), 14)
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "unable to hash password")
+			return
+		}
+		updated.Password = string(password)
+	}
+
+	version, ok := checkIfMatch(w, req, current.Version)
+	if !ok {
+		return
+	}
+	// without If-Match the fields left out were still merged from current,
+	// so writing over a newer version would undo someone else's change
+	conditional := version != database.AnyVersion
+	if !conditional {
+		version = current.Version
+	}
+
+	user, err := cfg.DB.UpdateUser(updated, version)
+	if errors.Is(err, database.ErrVersionMismatch) && conditional {
+		respondWithError(w, http.StatusPreconditionFailed, err.Error())
+		return
+	}
+	if errors.Is(err, database.ErrVersionMismatch) {
+		respondWithError(w, http.StatusConflict, "user was changed by another request, retry")
+		return
+	}
+	if errors.Is(err, database.ErrHandleTaken) {
+		respondWithError(w, http.StatusConflict, err.Error())
+		return
+	}
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "unable to update user login info")
 		return
 	}
 
+	w.Header().Set("ETag", etag(user.Version))
 	responseWithJSON(w, http.StatusOK, database.User{
 		Id:          user.Id,
 		Email:       user.Email,
+		Handle:      user.Handle,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarURL:   user.AvatarURL,
 		Token:       user.Token,
 		IsChirpyRed: user.IsChirpyRed,
+		Version:     user.Version,
 	})
 }
 
@@ -105,6 +243,7 @@ func (cfg *apiConfig) loginUsersHadler(w http.ResponseWriter, req *http.Request)
 	type response struct {
 		ID           int    `json:"id"`
 		Email        string `json:"email"`
+		Handle       string `json:"handle,omitempty"`
 		Token        string `json:"token"`
 		RefreshToken string `json:"refresh_token"`
 		IsChirpyRed  bool   `json:"is_chirpy_red"`
@@ -150,6 +289,7 @@ func (cfg *apiConfig) loginUsersHadler(w http.ResponseWriter, req *http.Request)
 	responseWithJSON(w, http.StatusOK, response{
 		ID:           user.Id,
 		Email:        user.Email,
+		Handle:       user.Handle,
 		Token:        jwtToken,
 		RefreshToken: refreshToken,
         IsChirpyRed: user.IsChirpyRed,
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/DuganChandler/goserver/internal/database"
+)
+
+// racingStore changes the user behind the handler's back right after the
+// handler reads it
+type racingStore struct {
+	database.Store
+	race func()
+}
+
+func (s racingStore) GetUserByID(id int) (database.User, error) {
+	user, err := s.Store.GetUserByID(id)
+	if s.race != nil {
+		s.race()
+	}
+	return user, err
+}
+
+func TestUpdateUserDoesNotLoseConcurrentUpdate(t *testing.T) {
+	cfg, mux, token := testServer(t)
+	db := cfg.DB
+	cfg.DB = racingStore{Store: db, race: func() {
+		user, err := db.GetUserByID(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		user.Bio = "written by the other request"
+		_, err = db.UpdateUser(user, user.Version)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}}
+
+	bearer := http.Header{"Authorization": {"Bearer " + token}}
+	rec := serve(mux, "PUT", "/api/users", `{"display_name": "Someone"}`, bearer)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("got %d for an update that raced another, want 409", rec.Code)
+	}
+
+	user, err := db.GetUserByID(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Bio != "written by the other request" {
+		t.Errorf("the other update was lost, bio is %q", user.Bio)
+	}
+
+	cfg.DB = db
+	rec = serve(mux, "PUT", "/api/users", `{"display_name": "Someone"}`, bearer)
+	if rec.Code != http.StatusOK {
+		t.Errorf("got %d retrying the update, want 200: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestSignupRequiresHandle(t *testing.T) {
+	cfg, mux, _ := testServer(t)
+
+	for _, body := range []string{
+		`{"email": "a@example.com", "password": "pw"}`,
+		`{"email": "a@example.com", "password": "pw", "handle": ""}`,
+		`{"email": "a@example.com", "password": "pw", "handle": "x!"}`,
+	} {
+		rec := serve(mux, "POST", "/api/users", body, nil)
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("got %d signing up with %s, want 400: %s", rec.Code, body, rec.Body)
+		}
+	}
+	_, err := cfg.DB.GetUserByEmail("a@example.com")
+	if err == nil {
+		t.Error("user without a valid handle was created")
+	}
+}